@@ -0,0 +1,104 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envDuration reads a Go duration (e.g. "4h", "90m") from the environment,
+// falling back to def when unset or invalid.
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid %s=%q, using default %s", name, value, def)
+		return def
+	}
+	return d
+}
+
+// envList reads a comma separated list from the environment.
+func envList(name string) []string {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// envInt reads an integer from the environment.
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid %s=%q, using default %d", name, value, def)
+		return def
+	}
+	return n
+}
+
+// envBool reads a boolean (true/false/1/0) from the environment.
+func envBool(name string, def bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid %s=%q, using default %t", name, value, def)
+		return def
+	}
+	return b
+}
+
+// envBytes reads a size such as "500M", "2G" or "1048576" from the environment.
+func envBytes(name string, def uint64) uint64 {
+	value := strings.ToUpper(strings.TrimSpace(os.Getenv(name)))
+	if value == "" {
+		return def
+	}
+
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+	multiplier := uint64(1)
+	if n := len(value); n > 0 {
+		switch value[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			value = value[:n-1]
+		}
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		log.Printf("⚠️  Invalid %s=%q, using default %d", name, os.Getenv(name), def)
+		return def
+	}
+	return uint64(f * float64(multiplier))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"av-control/internal/services"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// runKeysCommand implements "av-control keys rotate|retire-legacy|list" on
+// the file in JWT_KEYS_FILE. The running server picks up a change by itself.
+func runKeysCommand(args []string) int {
+	path := os.Getenv("JWT_KEYS_FILE")
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "JWT_KEYS_FILE is not set")
+		return 1
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: av-control keys rotate [-alg EdDSA|ES256|HS256] [-overlap 1440h] | keys retire-legacy [-in 0s] | keys list")
+		return 2
+	}
+
+	switch args[0] {
+	case "rotate":
+		fs := flag.NewFlagSet("keys rotate", flag.ExitOnError)
+		alg := fs.String("alg", services.AlgEdDSA, "signing algorithm of the new key (EdDSA, ES256 or HS256)")
+		overlap := fs.Duration("overlap", 60*24*time.Hour, "how long the previous key still verifies tokens")
+		fs.Parse(args[1:])
+
+		key, err := services.RotateKeysFile(path, *alg, *overlap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Rotation failed: %v\n", err)
+			return 1
+		}
+		fmt.Printf("🔑 New active key %s (%s) in %s\n", key.ID, key.Alg, path)
+		fmt.Printf("   The previous key keeps verifying tokens for %s\n", *overlap)
+		return 0
+
+	case "retire-legacy":
+		fs := flag.NewFlagSet("keys retire-legacy", flag.ExitOnError)
+		in := fs.Duration("in", 0, "how long JWT_SECRET still verifies tokens without a kid")
+		fs.Parse(args[1:])
+
+		at, err := services.RetireLegacyKeyFile(path, time.Now().Add(*in))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Retirement failed: %v\n", err)
+			return 1
+		}
+		fmt.Printf("🔑 JWT_SECRET stops verifying tokens at %s\n", at.Local().Format("2006-01-02 15:04"))
+		return 0
+
+	case "list":
+		active, keys, err := services.ListKeysFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot read %s: %v\n", path, err)
+			return 1
+		}
+		for _, k := range keys {
+			status := "verify only"
+			switch {
+			case k.ID == active:
+				status = "active"
+			case k.RetiresAt != nil && time.Now().After(*k.RetiresAt):
+				status = "retired"
+			}
+			retires := "-"
+			if k.RetiresAt != nil {
+				retires = k.RetiresAt.Local().Format("2006-01-02 15:04")
+			}
+			created := "-"
+			if !k.CreatedAt.IsZero() {
+				created = k.CreatedAt.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Printf("%-10s %-6s created %-16s  retires %-16s  %s\n",
+				k.ID, k.Alg, created, retires, status)
+		}
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown keys command %q\n", args[0])
+		return 2
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func main() {
@@ -29,6 +30,11 @@ func main() {
 		log.Println("No .env file found, using system environment variables")
 	}
 
+	// "av-control keys ..." manages the JWT signing keys and exits
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		os.Exit(runKeysCommand(os.Args[2:]))
+	}
+
 	// 0. Parse command line flags
 	useMock := flag.Bool("mock", false, "Use mock hardware client for testing")
 	flag.Parse()
@@ -49,15 +55,40 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	if err := database.SeedDatabase(db); err != nil {
+	// Password policy and hashing, also used for the seeded admin
+	passwords, err := services.NewPasswords(services.PasswordConfig{
+		MinLength:     envInt("PASSWORD_MIN_LENGTH", 8),
+		MinClasses:    envInt("PASSWORD_MIN_CLASSES", 2),
+		BannedFile:    os.Getenv("PASSWORD_BANNED_FILE"),
+		Hash:          os.Getenv("PASSWORD_HASH"),
+		BcryptCost:    envInt("BCRYPT_COST", bcrypt.DefaultCost),
+		Argon2Memory:  uint32(envBytes("ARGON2_MEMORY", 64<<20) >> 10),
+		Argon2Time:    uint32(envInt("ARGON2_TIME", 3)),
+		Argon2Threads: uint8(envInt("ARGON2_THREADS", 2)),
+	})
+	if err != nil {
+		log.Fatalf("Invalid password settings: %v", err)
+	}
+
+	// The first admin is admin/ADMIN_INITIAL_PASSWORD (admin123), to be
+	// changed at the first login, or is created with a setup token
+	setupToken := os.Getenv("ADMIN_SETUP_TOKEN")
+	if setupToken == "auto" {
+		bytes := make([]byte, 24)
+		rand.Read(bytes)
+		setupToken = base64.RawURLEncoding.EncodeToString(bytes)
+	}
+	seed := database.AdminSeed{Password: os.Getenv("ADMIN_INITIAL_PASSWORD"), Skip: setupToken != ""}
+	if err := database.SeedDatabase(db, passwords, seed); err != nil {
 		log.Fatalf("Failed to seed database: %v", err)
 	}
 
-	// 2. Load or generate JWT secret
+	// 2. Load JWT signing keys (JWT_KEYS_FILE) or secret
 	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
+	jwtKeysFile := os.Getenv("JWT_KEYS_FILE")
+	if jwtSecret == "" && jwtKeysFile == "" {
 		if os.Getenv("GIN_MODE") == "release" {
-			log.Fatal("❌ JWT_SECRET environment variable is required in production")
+			log.Fatal("❌ JWT_SECRET or JWT_KEYS_FILE environment variable is required in production")
 		}
 		// Development fallback
 		bytes := make([]byte, 32)
@@ -65,6 +96,14 @@ func main() {
 		jwtSecret = base64.StdEncoding.EncodeToString(bytes)
 		log.Println("⚠️  Using random JWT secret (development only)")
 	}
+	jwtKeys, err := services.NewKeySet(services.KeySetConfig{
+		File:   jwtKeysFile,
+		Secret: jwtSecret,
+		Alg:    os.Getenv("JWT_SIGNING_ALG"),
+	})
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
 
 	// 3. Create Hardware Client (MOCK or REAL)
 	var hwClient hardware.HardwareClient
@@ -87,8 +126,13 @@ func main() {
 	// 4. Setup Gin Router
 	r := gin.Default()
 
-	// Trust proxy from local network only
-	if err := r.SetTrustedProxies([]string{"127.0.0.1", "192.168.0.0/16"}); err != nil {
+	// Only a reverse proxy on this host may set X-Forwarded-For: the client IP
+	// keys the login lockout, so any other client must not be able to choose it
+	trustedProxies := envList("TRUSTED_PROXIES")
+	if trustedProxies == nil {
+		trustedProxies = []string{"127.0.0.1", "::1"}
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
 		log.Printf("⚠️  Failed to set trusted proxies: %v", err)
 	}
 
@@ -100,7 +144,7 @@ func main() {
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{corsOrigins},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
@@ -181,7 +225,92 @@ func main() {
 	go hub.Run()
 
 	// Create status poller (polls every 5 seconds)
-	statusPoller := services.NewStatusPoller(hwClient, hub, 5*time.Second)
+	pollInterval := 5 * time.Second
+	statusPoller := services.NewStatusPoller(hwClient, hub, pollInterval)
+
+	// Create storage guard (retention policy and disk space alerts)
+	recordingsDir := os.Getenv("RECORDINGS_DIR")
+	storageGuard := services.NewStorageGuard(db, hub, recordingsDir, services.RetentionPolicy{
+		KeepDays:      envInt("RECORDINGS_KEEP_DAYS", 0),
+		MaxTotalBytes: envBytes("RECORDINGS_MAX_TOTAL_SIZE", 0),
+		KeepTagged:    envBool("RECORDINGS_KEEP_TAGGED", true),
+		MinFreeBytes:  envBytes("RECORDINGS_MIN_FREE_SPACE", 500<<20),
+		WarnFreeBytes: envBytes("RECORDINGS_WARN_FREE_SPACE", 2<<30),
+		Interval:      envDuration("RECORDINGS_RETENTION_INTERVAL", time.Hour),
+	})
+	storageGuard.Start()
+	defer storageGuard.Stop()
+
+	// Create recording catalog and archiver (copies finished recordings off the device)
+	recordingCatalog := services.NewRecordingCatalog(db)
+
+	archiveTarget, err := services.NewArchiveTarget(services.ArchiveConfig{
+		Target:         os.Getenv("ARCHIVE_TARGET"),
+		LocalPath:      os.Getenv("ARCHIVE_LOCAL_PATH"),
+		SFTPAddr:       os.Getenv("ARCHIVE_SFTP_ADDR"),
+		SFTPUser:       os.Getenv("ARCHIVE_SFTP_USER"),
+		SFTPPassword:   os.Getenv("ARCHIVE_SFTP_PASSWORD"),
+		SFTPKeyFile:    os.Getenv("ARCHIVE_SFTP_KEY_FILE"),
+		SFTPKnownHosts: os.Getenv("ARCHIVE_SFTP_KNOWN_HOSTS"),
+		SFTPInsecure:   envBool("ARCHIVE_SFTP_INSECURE", false),
+		SFTPPath:       os.Getenv("ARCHIVE_SFTP_PATH"),
+		S3Endpoint:     os.Getenv("ARCHIVE_S3_ENDPOINT"),
+		S3Region:       os.Getenv("ARCHIVE_S3_REGION"),
+		S3Bucket:       os.Getenv("ARCHIVE_S3_BUCKET"),
+		S3Prefix:       os.Getenv("ARCHIVE_S3_PREFIX"),
+		S3AccessKey:    os.Getenv("ARCHIVE_S3_ACCESS_KEY"),
+		S3SecretKey:    os.Getenv("ARCHIVE_S3_SECRET_KEY"),
+	})
+	if err != nil {
+		log.Fatalf("Invalid archive configuration: %v", err)
+	}
+	archiver := services.NewArchiver(db, hwClient, archiveTarget, recordingsDir,
+		envInt("ARCHIVE_MAX_ATTEMPTS", 5), envDuration("ARCHIVE_RETRY_DELAY", time.Minute))
+	recordingCatalog.OnFinished(archiver.OnRecordingFinished)
+	archiver.Start()
+	defer archiver.Stop()
+
+	// Create recording supervisor (auto-stop and watchdog)
+	recordingSupervisor := services.NewRecordingSupervisor(hwClient, hub, recordingCatalog, storageGuard, services.RecordingLimits{
+		MaxDuration:   envDuration("RECORDING_MAX_DURATION", 4*time.Hour),
+		WarningBefore: envDuration("RECORDING_WARNING_BEFORE", 5*time.Minute),
+		IdleTimeout:   envDuration("RECORDING_IDLE_TIMEOUT", 0),
+		EndPresets:    envList("RECORDING_END_PRESETS"),
+	})
+	statusPoller.AddObserver(recordingSupervisor)
+	recordingSupervisor.Start()
+	defer recordingSupervisor.Stop()
+
+	// Log every track that actually plays (music licensing reports)
+	serviceSchedule := services.NewSchedule(db)
+	playTracker := services.NewPlayTracker(db, serviceSchedule, pollInterval, envDuration("PLAYLOG_MIN_PLAYED", 30*time.Second))
+	statusPoller.AddObserver(playTracker)
+	defer playTracker.Stop()
+
+	// Actions of presets, macros, rules and background music, blocked while
+	// the emergency mute is active
+	songIndex := services.NewSongIndex(db, hwClient, envDuration("SONG_CACHE_TTL", 10*time.Minute))
+	actionExecutor := services.NewActionExecutor(db, hwClient, songIndex)
+	emergency := services.NewEmergency(db, hwClient, actionExecutor, hub)
+
+	// Background music inside configured windows, paused for services and preset loads
+	backgroundMusic := services.NewBackgroundMusic(db, hwClient, actionExecutor, hub, serviceSchedule)
+	backgroundMusic.Start()
+	defer backgroundMusic.Stop()
+
+	// Lower music controls while the microphones are open
+	ducker := services.NewDucker(db, hwClient, envDuration("DUCKING_POLL_INTERVAL", 2*time.Second))
+	hub.AddCommandListener(ducker.OnCommand)
+	ducker.Start()
+	defer ducker.Stop()
+
+	// Automation rules run on state changes, commands and time
+	ruleEngine := services.NewRuleEngine(db, actionExecutor, hub)
+	statusPoller.AddObserver(ruleEngine)
+	hub.AddCommandListener(ruleEngine.OnCommand)
+	ruleEngine.Start()
+	defer ruleEngine.Stop()
+
 	statusPoller.Start()
 	defer statusPoller.Stop()
 
@@ -191,10 +320,49 @@ func main() {
 	defer auditService.Shutdown()
 
 	// Create handlers
-	authHandler := handlers.NewAuthHandler(db, jwtSecret)
-	deviceHandler := handlers.NewHandler(db, hwClient, hub)
-	wsHandler := handlers.NewWebSocketHandler(hub, jwtSecret)
-	userHandler := handlers.NewUserHandler(db)
+	roleStore := services.NewRoleStore(db)
+	accessControl := services.NewAccessControl(db)
+	sessionManager := services.NewSessionManager(db, roleStore, envInt("SESSION_MAX_PER_USER", 5))
+	authenticator := services.NewAuthenticator(jwtKeys, db)
+	loginGuard := services.NewLoginGuard(db, auditService, services.LoginGuardConfig{
+		DelayAfter:      envInt("LOGIN_DELAY_AFTER", 3),
+		BaseDelay:       envDuration("LOGIN_BASE_DELAY", time.Second),
+		MaxDelay:        envDuration("LOGIN_MAX_DELAY", time.Minute),
+		LockoutAfter:    envInt("LOGIN_LOCKOUT_AFTER", 10),
+		LockoutDuration: envDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		IPLockoutAfter:  envInt("LOGIN_IP_LOCKOUT_AFTER", 30),
+	})
+	authHandler := handlers.NewAuthHandler(db, jwtKeys, roleStore, auditService, sessionManager, loginGuard, passwords)
+	sessionHandler := handlers.NewSessionHandler(db, sessionManager)
+	setupHandler := handlers.NewSetupHandler(db, auditService, passwords, setupToken)
+	recordingNamer := services.NewRecordingNamer(db, hwClient, recordingsDir)
+	presetDirectory := services.NewPresetDirectory(db, hwClient, actionExecutor)
+	presetDirectory.OnLoaded(backgroundMusic.PresetLoaded)
+
+	// Preset load guard (PRESET_GUARD_RULES=none disables it)
+	guardRules := envList("PRESET_GUARD_RULES")
+	if guardRules == nil {
+		guardRules = []string{services.GuardRecorderActive, services.GuardPlayerPlaying, services.GuardServiceSoon}
+	}
+	presetGuard := services.NewPresetGuard(hwClient, serviceSchedule, backgroundMusic, services.PresetGuardConfig{
+		Rules:         guardRules,
+		ServiceWindow: envDuration("PRESET_GUARD_SERVICE_WINDOW", 15*time.Minute),
+	})
+	deviceHandler := handlers.NewHandler(db, hwClient, hub, recordingSupervisor, recordingNamer, presetDirectory, presetGuard, songIndex, accessControl)
+	wsHandler := handlers.NewWebSocketHandler(hub, authenticator)
+	userHandler := handlers.NewUserHandler(db, roleStore, auditService, loginGuard, passwords, sessionManager)
+	roleHandler := handlers.NewRoleHandler(roleStore)
+	accessRuleHandler := handlers.NewAccessRuleHandler(db, accessControl)
+	recordingHandler := handlers.NewRecordingHandler(recordingCatalog, storageGuard, archiver, hwClient, recordingsDir)
+	recordingTemplateHandler := handlers.NewRecordingTemplateHandler(db, recordingNamer)
+	presetHandler := handlers.NewPresetHandler(presetDirectory)
+	scheduleHandler := handlers.NewScheduleHandler(db, serviceSchedule)
+	automationHandler := handlers.NewAutomationHandler(db, hub, actionExecutor)
+	reportHandler := handlers.NewReportHandler(db)
+	backgroundMusicHandler := handlers.NewBackgroundMusicHandler(db, backgroundMusic)
+	duckingHandler := handlers.NewDuckingHandler(db, ducker)
+	ruleHandler := handlers.NewRuleHandler(db, ruleEngine)
+	emergencyHandler := handlers.NewEmergencyHandler(emergency, hub)
 
 	// WebSocket endpoint
 	r.GET("/ws", wsHandler.HandleWebSocket)
@@ -204,28 +372,183 @@ func main() {
 	// ========================================
 	api := r.Group("/api")
 	{
+		// Permission checks shared by the device and automation routes
+		canControlPlayer := middleware.RequirePermission(roleStore, models.PermPlayerControl)
+		canSetControls := middleware.RequirePermission(roleStore, models.PermControlsSet)
+		canLoadPresets := middleware.RequirePermission(roleStore, models.PermPresetsLoad)
+		canManageRecorder := middleware.RequirePermission(roleStore, models.PermRecorderManage)
+		canRunAutomation := middleware.RequirePermission(roleStore, models.PermAutomationRun)
+
 		// AUTH ENDPOINTS
 		auth := api.Group("/auth")
 		{
 			auth.POST("/login", authHandler.Login)
-			auth.POST("/logout", middleware.JWTAuthMiddleware(jwtSecret, db), authHandler.Logout)
-			auth.GET("/me", middleware.JWTAuthMiddleware(jwtSecret, db), authHandler.GetMe)
+			auth.GET("/setup", setupHandler.GetSetup)
+			auth.POST("/setup", setupHandler.Setup)
+			auth.POST("/logout", middleware.JWTAuthMiddleware(authenticator), authHandler.Logout)
+			auth.GET("/me", middleware.JWTAuthMiddleware(authenticator), authHandler.GetMe)
 			auth.POST("/refresh", authHandler.RefreshToken)
+			auth.POST("/password", middleware.JWTAuthMiddleware(authenticator), authHandler.ChangePassword)
+			auth.GET("/sessions", middleware.JWTAuthMiddleware(authenticator), sessionHandler.ListOwnSessions)
+			auth.DELETE("/sessions/:id", middleware.JWTAuthMiddleware(authenticator), sessionHandler.RevokeOwnSession)
 		}
 
-		// USER MANAGEMENT (Admin Only)
+		// ACTIVE SESSIONS of every user (Admin only)
+		sessions := api.Group("/sessions")
+		sessions.Use(middleware.JWTAuthMiddleware(authenticator))
+		sessions.Use(middleware.RequireRole("admin"))
+		{
+			sessions.GET("", sessionHandler.ListSessions)
+			sessions.DELETE("/:id", sessionHandler.RevokeSession)
+			sessions.DELETE("/user/:user_id", sessionHandler.RevokeUserSessions)
+		}
+
+		// USER MANAGEMENT
 		users := api.Group("/users")
-		users.Use(middleware.JWTAuthMiddleware(jwtSecret, db))
-		users.Use(middleware.RequireRole("admin"))
+		users.Use(middleware.JWTAuthMiddleware(authenticator))
+		users.Use(middleware.RequirePermission(roleStore, models.PermUsersManage))
 		{
 			users.POST("", userHandler.CreateUser)
 			users.GET("", userHandler.ListUsers)
+			users.GET("/audit", middleware.RequireRole("admin"), userHandler.ListAuditLog)
+			users.GET("/login-blocks", middleware.RequireRole("admin"), userHandler.ListLoginBlocks)
+			users.DELETE("/login-blocks/:ip", middleware.RequireRole("admin"), userHandler.UnblockIP)
+			users.PATCH("/:id", userHandler.UpdateUser)
 			users.DELETE("/:id", userHandler.DeleteUser)
+			users.POST("/:id/activate", userHandler.ActivateUser)
+			users.POST("/:id/deactivate", userHandler.DeactivateUser)
+			users.POST("/:id/reset-password", userHandler.ResetPassword)
+			users.POST("/:id/unlock", userHandler.UnlockUser)
+		}
+
+		// ROLES AND PERMISSIONS (Admin only)
+		roles := api.Group("/roles")
+		roles.Use(middleware.JWTAuthMiddleware(authenticator))
+		roles.Use(middleware.RequireRole("admin"))
+		{
+			roles.GET("", roleHandler.ListRoles)
+			roles.GET("/permissions", roleHandler.ListPermissions)
+			roles.PUT("/:name", roleHandler.SaveRole)
+			roles.DELETE("/:name", roleHandler.DeleteRole)
+		}
+
+		// ACCESS RULES per control, preset and source (Admin only)
+		accessRules := api.Group("/access-rules")
+		accessRules.Use(middleware.JWTAuthMiddleware(authenticator))
+		accessRules.Use(middleware.RequireRole("admin"))
+		{
+			accessRules.GET("", accessRuleHandler.ListRules)
+			accessRules.POST("", accessRuleHandler.CreateRule)
+			accessRules.PUT("/:id", accessRuleHandler.UpdateRule)
+			accessRules.DELETE("/:id", accessRuleHandler.DeleteRule)
+		}
+
+		// RECORDING LIBRARY (everyone can browse, recorder managers edit tags)
+		recordings := api.Group("/recordings")
+		recordings.Use(middleware.JWTAuthMiddleware(authenticator))
+		{
+			recordings.GET("", recordingHandler.ListRecordings)
+			recordings.GET("/storage", recordingHandler.GetStorage)
+			recordings.POST("/storage/cleanup", middleware.RequireRole("admin"), recordingHandler.RunRetention)
+
+			// Filename templates (Admin manages, everyone can preview)
+			templates := recordings.Group("/templates")
+			{
+				templates.GET("", recordingTemplateHandler.ListTemplates)
+				templates.POST("/preview", recordingTemplateHandler.PreviewTemplate)
+				templates.POST("", middleware.RequireRole("admin"), recordingTemplateHandler.CreateTemplate)
+				templates.PUT("/:id", middleware.RequireRole("admin"), recordingTemplateHandler.UpdateTemplate)
+				templates.DELETE("/:id", middleware.RequireRole("admin"), recordingTemplateHandler.DeleteTemplate)
+			}
+
+			recordings.GET("/:id", recordingHandler.GetRecording)
+			recordings.PATCH("/:id", canManageRecorder, recordingHandler.UpdateRecording)
+			recordings.GET("/:id/download", recordingHandler.DownloadRecording)
+			recordings.POST("/:id/archive", middleware.RequireRole("admin"), recordingHandler.ArchiveRecording)
+			recordings.DELETE("/:id/archive", middleware.RequireRole("admin"), recordingHandler.ClearArchive)
+		}
+
+		// SERVICE SCHEDULE (Admin manages, everyone can read)
+		schedule := api.Group("/schedule")
+		schedule.Use(middleware.JWTAuthMiddleware(authenticator))
+		{
+			schedule.GET("", scheduleHandler.GetSchedule)
+			schedule.GET("/services", scheduleHandler.ListServices)
+			schedule.POST("/services", middleware.RequireRole("admin"), scheduleHandler.CreateService)
+			schedule.PUT("/services/:id", middleware.RequireRole("admin"), scheduleHandler.UpdateService)
+			schedule.DELETE("/services/:id", middleware.RequireRole("admin"), scheduleHandler.DeleteService)
+		}
+
+		// BACKGROUND MUSIC (Admin configures, everyone can see the state)
+		music := api.Group("/background-music")
+		music.Use(middleware.JWTAuthMiddleware(authenticator))
+		{
+			music.GET("", backgroundMusicHandler.GetBackgroundMusic)
+			music.PUT("/settings", middleware.RequireRole("admin"), backgroundMusicHandler.UpdateSettings)
+			music.POST("/windows", middleware.RequireRole("admin"), backgroundMusicHandler.CreateWindow)
+			music.PUT("/windows/:id", middleware.RequireRole("admin"), backgroundMusicHandler.UpdateWindow)
+			music.DELETE("/windows/:id", middleware.RequireRole("admin"), backgroundMusicHandler.DeleteWindow)
+		}
+
+		// AUTOMATIC DUCKING (Admin configures, everyone can see the state)
+		ducking := api.Group("/ducking")
+		ducking.Use(middleware.JWTAuthMiddleware(authenticator))
+		{
+			ducking.GET("", duckingHandler.GetDucking)
+			ducking.POST("/rules", middleware.RequireRole("admin"), duckingHandler.CreateRule)
+			ducking.PUT("/rules/:id", middleware.RequireRole("admin"), duckingHandler.UpdateRule)
+			ducking.DELETE("/rules/:id", middleware.RequireRole("admin"), duckingHandler.DeleteRule)
+		}
+
+		// REPORTS (Admin only)
+		reports := api.Group("/reports")
+		reports.Use(middleware.JWTAuthMiddleware(authenticator))
+		reports.Use(middleware.RequireRole("admin"))
+		{
+			reports.GET("/music-usage", reportHandler.GetMusicUsage)
+			reports.GET("/plays", reportHandler.ListPlays)
+		}
+
+		// AUTOMATION: control snapshots and macros (Admin edits, everyone can run)
+		automation := api.Group("/automation")
+		automation.Use(middleware.JWTAuthMiddleware(authenticator))
+		automation.Use(middleware.EmergencyLock(emergency))
+		{
+			automation.GET("/snapshots", automationHandler.ListSnapshots)
+			automation.POST("/snapshots", middleware.RequireRole("admin"), automationHandler.CaptureSnapshot)
+			automation.DELETE("/snapshots/:id", middleware.RequireRole("admin"), automationHandler.DeleteSnapshot)
+			automation.POST("/snapshots/:id/apply", canRunAutomation, automationHandler.ApplySnapshot)
+
+			automation.GET("/macros", automationHandler.ListMacros)
+			automation.POST("/macros", middleware.RequireRole("admin"), automationHandler.CreateMacro)
+			automation.PUT("/macros/:id", middleware.RequireRole("admin"), automationHandler.UpdateMacro)
+			automation.DELETE("/macros/:id", middleware.RequireRole("admin"), automationHandler.DeleteMacro)
+			automation.POST("/macros/:id/run", canRunAutomation, automationHandler.RunMacro)
+
+			rules := automation.Group("/rules", middleware.RequireRole("admin"))
+			rules.GET("", ruleHandler.ListRules)
+			rules.GET("/history", ruleHandler.GetHistory)
+			rules.POST("", ruleHandler.CreateRule)
+			rules.POST("/test", ruleHandler.TestNewRule)
+			rules.PUT("/:id", ruleHandler.UpdateRule)
+			rules.DELETE("/:id", ruleHandler.DeleteRule)
+			rules.POST("/:id/test", ruleHandler.TestRule)
+		}
+
+		// EMERGENCY MUTE (anyone can trigger it, only admins restore)
+		emergencyRoutes := api.Group("/device/emergency")
+		emergencyRoutes.Use(middleware.JWTAuthMiddleware(authenticator))
+		emergencyRoutes.Use(middleware.AuditMiddleware(auditService))
+		{
+			emergencyRoutes.GET("", emergencyHandler.GetState)
+			emergencyRoutes.POST("/mute-all", emergencyHandler.MuteAll)
+			emergencyRoutes.POST("/restore", middleware.RequireRole("admin"), emergencyHandler.Restore)
 		}
 
 		// DEVICE ENDPOINTS (Protected with JWT and Audited)
 		device := api.Group("/device")
-		device.Use(middleware.JWTAuthMiddleware(jwtSecret, db))
+		device.Use(middleware.JWTAuthMiddleware(authenticator))
+		device.Use(middleware.EmergencyLock(emergency))
 		device.Use(middleware.AuditMiddleware(auditService))
 		{
 			// System Status
@@ -236,30 +559,47 @@ func main() {
 			{
 				presets.GET("", deviceHandler.GetPresets)
 				presets.GET("/current", deviceHandler.GetCurrentPreset)
-				presets.POST("/load", deviceHandler.LoadPreset)
+				presets.POST("/load", canLoadPresets, deviceHandler.LoadPreset)
+
+				// Display metadata overlay (Admin only)
+				presets.GET("/meta", middleware.RequireRole("admin"), presetHandler.ListPresetMeta)
+				presets.PUT("/:id/meta", middleware.RequireRole("admin"), presetHandler.UpdatePresetMeta)
+				presets.DELETE("/:id/meta", middleware.RequireRole("admin"), presetHandler.DeletePresetMeta)
+
+				// Post-load hooks (Admin only)
+				presets.GET("/:id/hooks", middleware.RequireRole("admin"), presetHandler.GetPresetHooks)
+				presets.PUT("/:id/hooks", middleware.RequireRole("admin"), presetHandler.UpdatePresetHooks)
+				presets.DELETE("/:id/hooks", middleware.RequireRole("admin"), presetHandler.DeletePresetHooks)
 			}
 
 			// PLAYER
 			player := device.Group("/player")
 			{
 				player.GET("/sources", deviceHandler.GetSources)
-				player.POST("/source", deviceHandler.SelectSource)
+				player.POST("/source", canControlPlayer, deviceHandler.SelectSource)
 				player.GET("/songs", deviceHandler.GetSongs)
-				player.POST("/song", deviceHandler.SelectSong)
-				player.POST("/play", deviceHandler.Play)
-				player.POST("/pause", deviceHandler.Pause)
-				player.POST("/stop", deviceHandler.Stop)
-				player.POST("/next", deviceHandler.Next)
-				player.POST("/previous", deviceHandler.Previous)
-				player.POST("/repeat", deviceHandler.SetRepeatMode)
+				player.GET("/songs/search", deviceHandler.SearchSongs)
+				player.POST("/songs/refresh", canControlPlayer, deviceHandler.RefreshSongs)
+				player.GET("/songs/tags", deviceHandler.GetSongTags)
+				player.PUT("/songs/tags", canControlPlayer, deviceHandler.SetSongTags)
+				player.POST("/song", canControlPlayer, deviceHandler.SelectSong)
+				player.POST("/play", canControlPlayer, deviceHandler.Play)
+				player.POST("/pause", canControlPlayer, deviceHandler.Pause)
+				player.POST("/stop", canControlPlayer, deviceHandler.Stop)
+				player.POST("/next", canControlPlayer, deviceHandler.Next)
+				player.POST("/previous", canControlPlayer, deviceHandler.Previous)
+				player.POST("/repeat", canControlPlayer, deviceHandler.SetRepeatMode)
+				player.POST("/seek", canControlPlayer, deviceHandler.Seek)
+				player.POST("/shuffle", canControlPlayer, deviceHandler.SetShuffle)
+				player.GET("/capabilities", deviceHandler.GetPlayerCapabilities)
 				player.GET("/status", deviceHandler.GetPlayerStatus)
 			}
 
 			// RECORDER
 			recorder := device.Group("/recorder")
 			{
-				recorder.POST("/start", deviceHandler.StartRecording)
-				recorder.POST("/stop", deviceHandler.StopRecording)
+				recorder.POST("/start", canManageRecorder, deviceHandler.StartRecording)
+				recorder.POST("/stop", canManageRecorder, deviceHandler.StopRecording)
 				recorder.GET("/status", deviceHandler.GetRecorderStatus)
 			}
 
@@ -270,7 +610,7 @@ func main() {
 				controls.GET("/volume/:id", deviceHandler.GetControlVolume) // NEW!
 				controls.GET("/mute/:id", deviceHandler.GetControlMute)     // NEW!
 				controls.GET("/:id", deviceHandler.GetControlValue)         // Fallback generico
-				controls.POST("/:id", deviceHandler.SetControlValue)
+				controls.POST("/:id", canSetControls, deviceHandler.SetControlValue)
 			}
 		}
 	}
@@ -307,8 +647,14 @@ func main() {
 	log.Printf("🔧 Mode: %s", gin.Mode())
 	log.Printf("🗄️  Database: %s", dbPath)
 
+	var admins int64
+	db.Model(&models.User{}).Where("role = ?", services.AdminRole).Count(&admins)
+	if admins == 0 && setupToken != "" {
+		log.Printf("🔑 No admin yet: create it with POST /api/auth/setup, setup token %s", setupToken)
+	}
+
 	if gin.Mode() != gin.ReleaseMode {
-		log.Println("📝 Default credentials: admin / admin123")
+		log.Println("📝 Default credentials: admin / admin123 (password change required at first login)")
 		log.Printf("🔌 WebSocket: ws://localhost:%s/ws?token=<JWT>", port)
 		log.Printf("📊 Debug endpoints: http://localhost:%s/debug/*", port)
 	}
@@ -44,7 +44,7 @@ fi
 
 # Check hardware connection
 echo -n "Hardware daemon: "
-CONNECTED=$(curl -s -H "Authorization: Bearer $(curl -s -X POST http://$SERVER:$PORT/api/auth/login -H 'Content-Type: application/json' -d '{"username":"admin","password":"admin123"}' | grep -o '"access_token":"[^"]*' | cut -d'"' -f4)" http://$SERVER:$PORT/api/device/status | grep -o '"connected":[^,]*' | cut -d':' -f2)
+CONNECTED=$(curl -s -H "Authorization: Bearer $(curl -s -X POST http://$SERVER:$PORT/api/auth/login -H 'Content-Type: application/json' -d "{\"username\":\"${ADMIN_USER:-admin}\",\"password\":\"${ADMIN_PASSWORD:-admin123}\"}" | grep -o '"access_token":"[^"]*' | cut -d'"' -f4)" http://$SERVER:$PORT/api/device/status | grep -o '"connected":[^,]*' | cut -d':' -f2)
 if [ "$CONNECTED" = "true" ]; then
     echo -e "${GREEN}Connected${NC}"
 else
@@ -43,6 +43,8 @@ api.interceptors.response.use(
 
                 const newAccessToken = response.data.access_token;
                 localStorage.setItem('access_token', newAccessToken);
+                // Refresh tokens are single use, keep the rotated one
+                localStorage.setItem('refresh_token', response.data.refresh_token);
 
                 // Retry original request with new token
                 originalRequest.headers.Authorization = `Bearer ${newAccessToken}`;
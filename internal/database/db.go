@@ -2,11 +2,13 @@ package database
 
 import (
 	"av-control/internal/models"
+	"av-control/internal/services"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/glebarez/sqlite"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -24,45 +26,142 @@ func InitDB(dbPath string) (*gorm.DB, error) {
 	// Auto-migrate models
 	err = db.AutoMigrate(
 		&models.User{},
+		&models.Role{},
+		&models.AccessRule{},
 		&models.Session{},
+		&models.UsedRefreshToken{},
 		&models.CommandLog{},
 		&models.UserAuditLog{},
+		&models.Recording{},
+		&models.RecordingTemplate{},
+		&models.PresetMeta{},
+		&models.ScheduledService{},
+		&models.Snapshot{},
+		&models.Macro{},
+		&models.PresetHook{},
+		&models.SongTag{},
+		&models.PlayLog{},
+		&models.BackgroundMusicWindow{},
+		&models.BackgroundMusicSettings{},
+		&models.DuckingRule{},
+		&models.AutomationRule{},
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := backfillRefreshExpiry(db); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
 
-func SeedDatabase(db *gorm.DB) error {
-	var count int64
-	db.Model(&models.User{}).Where("role = ?", "admin").Count(&count)
+// legacyRefreshTTL is how long refresh tokens were valid, from the login,
+// before they were rotated at every refresh.
+const legacyRefreshTTL = 60 * 24 * time.Hour
 
-	if count == 0 {
-		log.Println("Creating default admin user...")
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
+// backfillRefreshExpiry sets the refresh expiry of sessions created before
+// it was stored, so they stay valid until their refresh token expires.
+func backfillRefreshExpiry(db *gorm.DB) error {
+	var sessions []models.Session
+	if err := db.Where("refresh_expires_at IS NULL").Find(&sessions).Error; err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		err := db.Model(&models.Session{}).Where("id = ?", s.ID).
+			Update("refresh_expires_at", s.CreatedAt.Add(legacyRefreshTTL)).Error
 		if err != nil {
 			return err
 		}
+	}
+	if len(sessions) > 0 {
+		log.Printf("Set the refresh expiry of %d sessions created before token rotation.", len(sessions))
+	}
+	return nil
+}
 
-		admin := models.User{
-			ID:           uuid.New().String(),
-			Username:     "admin",
-			PasswordHash: string(hashedPassword),
-			Role:         "admin",
-			FullName:     "Administrator",
-			IsActive:     true,
-			CreatedBy:    "system",
-		}
+// DefaultAdminPassword is the documented password of the seeded admin when
+// none is configured. It is refused by the policy, so it must be changed at
+// the first login.
+const DefaultAdminPassword = "admin123"
+
+// AdminSeed controls how the first admin account is created.
+type AdminSeed struct {
+	Password string // initial password of "admin" (DefaultAdminPassword), to be changed at the first login
+	Skip     bool   // create no admin: the first one comes from the setup token
+}
 
-		if err := db.Create(&admin).Error; err != nil {
+func SeedDatabase(db *gorm.DB, passwords *services.Passwords, seed AdminSeed) error {
+	// Built-in roles: only created when missing, admins may have edited them
+	for _, role := range models.DefaultRoles {
+		if err := db.Where(models.Role{Name: role.Name}).FirstOrCreate(&role).Error; err != nil {
 			return err
 		}
-		log.Println("Default admin user created.")
-	} else {
+	}
+
+	var count int64
+	db.Model(&models.User{}).Where("role = ?", "admin").Count(&count)
+
+	if count > 0 {
 		log.Println("Admin user already exists, skipping seed.")
+		return flagDefaultAdminPasswords(db, passwords)
+	}
+	if seed.Skip {
+		log.Println("No admin user yet, waiting for the setup token.")
+		return nil
 	}
 
+	password := seed.Password
+	if password == "" {
+		password = DefaultAdminPassword
+	} else if err := passwords.Check(password, "admin"); err != nil {
+		return fmt.Errorf("ADMIN_INITIAL_PASSWORD: %w", err)
+	}
+
+	log.Println("Creating default admin user...")
+	hashedPassword, err := passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	admin := models.User{
+		ID:                 uuid.New().String(),
+		Username:           "admin",
+		PasswordHash:       hashedPassword,
+		Role:               "admin",
+		FullName:           "Administrator",
+		IsActive:           true,
+		CreatedBy:          "system",
+		MustChangePassword: true, // the seed password is known or written in the config
+	}
+
+	if err := db.Create(&admin).Error; err != nil {
+		return err
+	}
+	log.Println("Default admin user created, the password must be changed at the first login.")
+	return nil
+}
+
+// flagDefaultAdminPasswords makes admins still using DefaultAdminPassword
+// change it, for installs seeded before the change was required. Admins who
+// ever changed their password are skipped, so the check runs once per account.
+func flagDefaultAdminPasswords(db *gorm.DB, passwords *services.Passwords) error {
+	var admins []models.User
+	err := db.Where("role = ? AND must_change_password = ? AND password_changed_at IS NULL", "admin", false).
+		Find(&admins).Error
+	if err != nil {
+		return err
+	}
+
+	for _, admin := range admins {
+		if ok, _ := passwords.Verify(admin.PasswordHash, DefaultAdminPassword); !ok {
+			continue
+		}
+		if err := db.Model(&admin).Update("must_change_password", true).Error; err != nil {
+			return err
+		}
+		log.Printf("⚠️  Admin %s still uses the default password, it must be changed at the next login.", admin.Username)
+	}
 	return nil
 }
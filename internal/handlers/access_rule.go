@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AccessRuleHandler manages the per-control, per-preset and per-source
+// access rules.
+type AccessRuleHandler struct {
+	db  *gorm.DB
+	acl *services.AccessControl
+}
+
+func NewAccessRuleHandler(db *gorm.DB, acl *services.AccessControl) *AccessRuleHandler {
+	return &AccessRuleHandler{db: db, acl: acl}
+}
+
+type AccessRuleRequest struct {
+	SubjectType  string `json:"subject_type" binding:"required"`
+	Subject      string `json:"subject" binding:"required"`
+	ResourceType string `json:"resource_type" binding:"required"`
+	ResourceID   string `json:"resource_id" binding:"required"`
+	Effect       string `json:"effect" binding:"required"`
+	Note         string `json:"note"`
+}
+
+type AccessRuleResponse struct {
+	ID           uint   `json:"id"`
+	SubjectType  string `json:"subject_type"`
+	Subject      string `json:"subject"`
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id"`
+	Effect       string `json:"effect"`
+	Note         string `json:"note,omitempty"`
+}
+
+func toAccessRuleResponse(r *models.AccessRule) AccessRuleResponse {
+	return AccessRuleResponse{
+		ID:           r.ID,
+		SubjectType:  r.SubjectType,
+		Subject:      r.Subject,
+		ResourceType: r.ResourceType,
+		ResourceID:   r.ResourceID,
+		Effect:       r.Effect,
+		Note:         r.Note,
+	}
+}
+
+// ListRules returns the rules, optionally filtered by subject_type,
+// subject and resource_type.
+func (h *AccessRuleHandler) ListRules(c *gin.Context) {
+	query := h.db.Order("resource_type, resource_id")
+	for _, field := range []string{"subject_type", "subject", "resource_type"} {
+		if value := c.Query(field); value != "" {
+			query = query.Where(field+" = ?", value)
+		}
+	}
+
+	var rules []models.AccessRule
+	if err := query.Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch access rules",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := make([]AccessRuleResponse, 0, len(rules))
+	for i := range rules {
+		response = append(response, toAccessRuleResponse(&rules[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AccessRuleHandler) CreateRule(c *gin.Context) {
+	var rule models.AccessRule
+	if !h.bindRule(c, &rule) {
+		return
+	}
+
+	if err := h.db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to create access rule",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.acl.Reload()
+	c.JSON(http.StatusCreated, toAccessRuleResponse(&rule))
+}
+
+func (h *AccessRuleHandler) UpdateRule(c *gin.Context) {
+	var rule models.AccessRule
+	if err := h.db.First(&rule, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Access rule not found",
+			ErrorCode: "NOT_FOUND",
+		})
+		return
+	}
+	if !h.bindRule(c, &rule) {
+		return
+	}
+
+	if err := h.db.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to update access rule",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.acl.Reload()
+	c.JSON(http.StatusOK, toAccessRuleResponse(&rule))
+}
+
+func (h *AccessRuleHandler) DeleteRule(c *gin.Context) {
+	if err := h.db.Unscoped().Delete(&models.AccessRule{}, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to delete access rule",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.acl.Reload()
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
+}
+
+func (h *AccessRuleHandler) bindRule(c *gin.Context, rule *models.AccessRule) bool {
+	var req AccessRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return false
+	}
+
+	rule.SubjectType = req.SubjectType
+	rule.Subject = req.Subject
+	rule.ResourceType = req.ResourceType
+	rule.ResourceID = req.ResourceID
+	rule.Effect = req.Effect
+	rule.Note = req.Note
+
+	if err := h.acl.Validate(rule); err != nil {
+		status, code := http.StatusInternalServerError, "INTERNAL_ERROR"
+		if errors.Is(err, services.ErrInvalidAccessRule) {
+			status, code = http.StatusBadRequest, "INVALID_ACCESS_RULE"
+		}
+		c.JSON(status, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: code,
+		})
+		return false
+	}
+	return true
+}
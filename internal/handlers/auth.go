@@ -2,27 +2,39 @@ package handlers
 
 import (
 	"av-control/internal/models"
+	"av-control/internal/services"
 	"crypto/sha256"
 	"encoding/hex"
-	"fmt"
+	"errors"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
 	db        *gorm.DB
-	jwtSecret []byte
+	keys      *services.KeySet
+	roles     *services.RoleStore
+	audit     *services.AuditService
+	sessions  *services.SessionManager
+	guard     *services.LoginGuard
+	passwords *services.Passwords
 }
 
-func NewAuthHandler(db *gorm.DB, jwtSecret string) *AuthHandler {
+func NewAuthHandler(db *gorm.DB, keys *services.KeySet, roles *services.RoleStore, audit *services.AuditService, sessions *services.SessionManager, guard *services.LoginGuard, passwords *services.Passwords) *AuthHandler {
 	return &AuthHandler{
 		db:        db,
-		jwtSecret: []byte(jwtSecret),
+		keys:      keys,
+		roles:     roles,
+		audit:     audit,
+		sessions:  sessions,
+		guard:     guard,
+		passwords: passwords,
 	}
 }
 
@@ -54,10 +66,17 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 2. Find user (case-insensitive username, active only)
+	// 2. Slow down IPs that keep failing
+	if wait := h.guard.CheckIP(c.ClientIP()); wait > 0 {
+		retryLater(c, 429, wait, "TOO_MANY_ATTEMPTS", "Too many failed logins, try again later")
+		return
+	}
+
+	// 3. Find user (case-insensitive username, active only)
 	var user models.User
 	result := h.db.Where("LOWER(username) = LOWER(?) AND is_active = ?", req.Username, true).First(&user)
 	if result.Error != nil {
+		h.guard.Failure(c.ClientIP(), req.Username, nil)
 		c.JSON(401, models.ErrorResponse{
 			Success:   false,
 			Error:     "Invalid credentials",
@@ -66,9 +85,19 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 3. Verify password
-	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
-	if err != nil {
+	// 4. Refuse locked or slowed down accounts before checking the password
+	if wait, locked := h.guard.CheckUser(&user); locked {
+		retryLater(c, 423, wait, "ACCOUNT_LOCKED", "Account locked after too many failed logins, try again later")
+		return
+	} else if wait > 0 {
+		retryLater(c, 429, wait, "TOO_MANY_ATTEMPTS", "Too many failed logins, try again later")
+		return
+	}
+
+	// 5. Verify password
+	ok, rehash := h.passwords.Verify(user.PasswordHash, req.Password)
+	if !ok {
+		h.guard.Failure(c.ClientIP(), req.Username, &user)
 		c.JSON(401, models.ErrorResponse{
 			Success:   false,
 			Error:     "Invalid credentials",
@@ -77,63 +106,61 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 4. Check for existing active session (LAST LOGIN WINS)
-	// Instead of blocking, we delete any existing active sessions for this user
-	h.db.Where("user_id = ?", user.ID).Delete(&models.Session{})
+	h.guard.Success(&user)
 
-	// 5. Generate JWT access token (24 HOURS)
-	accessTokenExpiry := time.Now().Add(24 * time.Hour)
-	accessClaims := jwt.MapClaims{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"role":     user.Role,
-		"exp":      accessTokenExpiry.Unix(),
+	// Upgrade hashes made with an older algorithm or cost
+	if rehash {
+		if hash, err := h.passwords.Hash(req.Password); err == nil {
+			h.db.Model(&user).Update("password_hash", hash)
+		}
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString(h.jwtSecret)
-	if err != nil {
+
+	// 6. Apply the role's concurrent session limit
+	if err := h.sessions.Admit(&user); err != nil {
+		if errors.Is(err, services.ErrSessionLimit) {
+			c.JSON(409, models.ErrorResponse{
+				Success:   false,
+				Error:     "Too many active sessions, log out from another device first",
+				ErrorCode: "SESSION_LIMIT",
+			})
+			return
+		}
 		c.JSON(500, models.ErrorResponse{
 			Success:   false,
-			Error:     "Failed to generate token",
-			ErrorCode: "TOKEN_GENERATION_ERROR",
+			Error:     "Failed to check sessions",
+			ErrorCode: "DATABASE_ERROR",
 		})
 		return
 	}
 
-	// 6. Generate refresh token (60 DAYS)
-	refreshTokenExpiry := time.Now().Add(60 * 24 * time.Hour)
-	refreshClaims := jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     refreshTokenExpiry.Unix(),
-	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString(h.jwtSecret)
+	// 7. Generate access (24 HOURS) and refresh (60 DAYS) tokens
+	tokens, err := h.signTokens(&user)
 	if err != nil {
 		c.JSON(500, models.ErrorResponse{
 			Success:   false,
-			Error:     "Failed to generate refresh token",
+			Error:     "Failed to generate token",
 			ErrorCode: "TOKEN_GENERATION_ERROR",
 		})
 		return
 	}
 
-	// 7. Hash tokens for storage (SHA256)
-	accessHash := sha256.Sum256([]byte(accessTokenString))
-	accessHashStr := hex.EncodeToString(accessHash[:])
-
-	refreshHash := sha256.Sum256([]byte(refreshTokenString))
-	refreshHashStr := hex.EncodeToString(refreshHash[:])
-
 	// 8. Create session in database
+	accessMethod := "local"
+	if services.IsRemoteAccess(c.ClientIP()) {
+		accessMethod = "remote"
+	}
+	now := time.Now()
 	session := models.Session{
 		ID:               uuid.New().String(),
 		UserID:           user.ID,
-		TokenHash:        accessHashStr,
-		RefreshTokenHash: refreshHashStr,
+		TokenHash:        hashToken(tokens.access),
+		RefreshTokenHash: hashToken(tokens.refresh),
 		DeviceInfo:       c.GetHeader("User-Agent"),
 		IPAddress:        c.ClientIP(),
-		AccessMethod:     "local", // TODO: detect remote vs local
-		ExpiresAt:        accessTokenExpiry,
+		AccessMethod:     accessMethod,
+		ExpiresAt:        tokens.accessExpiry,
+		RefreshExpiresAt: tokens.refreshExpiry,
+		LastActivity:     now,
 	}
 
 	if err := h.db.Create(&session).Error; err != nil {
@@ -146,19 +173,20 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	// 9. Update user last_login
-	now := time.Now()
 	h.db.Model(&user).Update("last_login", &now)
 
 	// 10. Return success response
 	c.JSON(200, LoginResponse{
-		AccessToken:  accessTokenString,
-		RefreshToken: refreshTokenString,
+		AccessToken:  tokens.access,
+		RefreshToken: tokens.refresh,
 		ExpiresIn:    86400, // 24 hours in seconds
 		User: gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"role":     user.Role,
-			"name":     user.FullName,
+			"id":                   user.ID,
+			"username":             user.Username,
+			"role":                 user.Role,
+			"name":                 user.FullName,
+			"permissions":          h.roles.Permissions(user.Role),
+			"must_change_password": user.MustChangePassword,
 		},
 	})
 }
@@ -175,9 +203,14 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		return
 	}
 
-	// Delete ALL sessions for this user
-	result := h.db.Where("user_id = ?", userID).Delete(&models.Session{})
-	if result.Error != nil {
+	// Only the current session ends, unless ?all=true logs out every device
+	var err error
+	if c.Query("all") == "true" {
+		_, err = h.sessions.RevokeUser(userID, "")
+	} else {
+		err = h.sessions.Revoke(c.GetString("session_id"), userID)
+	}
+	if err != nil && !errors.Is(err, services.ErrSessionNotFound) {
 		c.JSON(500, models.ErrorResponse{
 			Success:   false,
 			Error:     "Failed to logout",
@@ -202,14 +235,8 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	}
 
 	// 2. Validate refresh token JWT
-	token, err := jwt.Parse(req.RefreshToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method")
-		}
-		return h.jwtSecret, nil
-	})
-
-	if err != nil || !token.Valid {
+	claims, err := h.keys.Parse(req.RefreshToken, services.TokenRefresh)
+	if err != nil {
 		c.JSON(401, models.ErrorResponse{
 			Success:   false,
 			Error:     "Invalid refresh token",
@@ -219,96 +246,136 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	}
 
 	// 3. Extract user_id from claims
-	claims, ok := token.Claims.(jwt.MapClaims)
+	userID, ok := claims["user_id"].(string)
 	if !ok {
 		c.JSON(401, models.ErrorResponse{
 			Success:   false,
-			Error:     "Invalid token claims",
+			Error:     "Invalid user_id in token",
 			ErrorCode: "INVALID_TOKEN",
 		})
 		return
 	}
 
-	userID, ok := claims["user_id"].(string)
-	if !ok {
+	// 4. Get user info for new tokens
+	var user models.User
+	if err := h.db.First(&user, "id = ? AND is_active = ?", userID, true).Error; err != nil {
 		c.JSON(401, models.ErrorResponse{
 			Success:   false,
-			Error:     "Invalid user_id in token",
-			ErrorCode: "INVALID_TOKEN",
+			Error:     "User not found or inactive",
+			ErrorCode: "USER_INACTIVE",
 		})
 		return
 	}
 
-	// 4. Hash refresh token and find session
-	refreshHash := sha256.Sum256([]byte(req.RefreshToken))
-	refreshHashStr := hex.EncodeToString(refreshHash[:])
-
-	var session models.Session
-	result := h.db.Where("refresh_token_hash = ? AND user_id = ? AND expires_at > ?",
-		refreshHashStr, userID, time.Now()).First(&session)
+	// 5. Generate new access and refresh tokens
+	tokens, err := h.signTokens(&user)
+	if err != nil {
+		c.JSON(500, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to generate new token",
+			ErrorCode: "TOKEN_GENERATION_ERROR",
+		})
+		return
+	}
 
-	if result.Error != nil {
+	// 6. Rotate: the presented refresh token stops working
+	_, err = h.sessions.Rotate(userID, hashToken(req.RefreshToken), services.TokenRotation{
+		AccessHash:       hashToken(tokens.access),
+		RefreshHash:      hashToken(tokens.refresh),
+		ExpiresAt:        tokens.accessExpiry,
+		RefreshExpiresAt: tokens.refreshExpiry,
+	})
+	switch {
+	case errors.Is(err, services.ErrRefreshReused):
+		c.JSON(401, models.ErrorResponse{
+			Success:   false,
+			Error:     "Refresh token already used, the session has been revoked",
+			ErrorCode: "REFRESH_TOKEN_REUSED",
+		})
+		return
+	case errors.Is(err, services.ErrSessionNotFound):
 		c.JSON(401, models.ErrorResponse{
 			Success:   false,
 			Error:     "Session not found or expired",
 			ErrorCode: "SESSION_EXPIRED",
 		})
 		return
-	}
-
-	// 5. Get user info for new token
-	var user models.User
-	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
+	case err != nil:
 		c.JSON(500, models.ErrorResponse{
 			Success:   false,
-			Error:     "User not found",
-			ErrorCode: "USER_NOT_FOUND",
+			Error:     "Failed to update session",
+			ErrorCode: "DATABASE_ERROR",
 		})
 		return
 	}
 
-	// 6. Generate new access token (24 HOURS)
-	newAccessTokenExpiry := time.Now().Add(24 * time.Hour)
-	newAccessClaims := jwt.MapClaims{
+	// 7. Return the new token pair
+	c.JSON(200, gin.H{
+		"access_token":  tokens.access,
+		"refresh_token": tokens.refresh,
+		"expires_in":    86400, // 24 hours
+	})
+}
+
+// retryLater refuses a login attempt, telling the client when to retry.
+func retryLater(c *gin.Context, status int, wait time.Duration, code, message string) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.JSON(status, models.ErrorResponse{
+		Success:   false,
+		Error:     message,
+		ErrorCode: code,
+	})
+}
+
+// tokenPair is a freshly signed access and refresh token.
+type tokenPair struct {
+	access        string
+	refresh       string
+	accessExpiry  time.Time
+	refreshExpiry time.Time
+}
+
+// signTokens creates the access (24 HOURS) and refresh (60 DAYS) tokens of
+// user. The jti claim keeps two refresh tokens signed in the same second
+// distinct.
+func (h *AuthHandler) signTokens(user *models.User) (tokenPair, error) {
+	pair := tokenPair{
+		accessExpiry:  time.Now().Add(24 * time.Hour),
+		refreshExpiry: time.Now().Add(60 * 24 * time.Hour),
+	}
+
+	accessClaims := jwt.MapClaims{
+		"typ":      services.TokenAccess,
 		"user_id":  user.ID,
 		"username": user.Username,
 		"role":     user.Role,
-		"exp":      newAccessTokenExpiry.Unix(),
+		"exp":      pair.accessExpiry.Unix(),
+		"jti":      uuid.New().String(),
 	}
-	newAccessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, newAccessClaims)
-	newAccessTokenString, err := newAccessToken.SignedString(h.jwtSecret)
+	access, err := h.keys.Sign(accessClaims)
 	if err != nil {
-		c.JSON(500, models.ErrorResponse{
-			Success:   false,
-			Error:     "Failed to generate new token",
-			ErrorCode: "TOKEN_GENERATION_ERROR",
-		})
-		return
+		return pair, err
 	}
 
-	// 7. Hash new access token
-	newAccessHash := sha256.Sum256([]byte(newAccessTokenString))
-	newAccessHashStr := hex.EncodeToString(newAccessHash[:])
-
-	// 8. Update session with new token hash and expiry
-	updates := map[string]interface{}{
-		"token_hash": newAccessHashStr,
-		"expires_at": newAccessTokenExpiry,
+	refreshClaims := jwt.MapClaims{
+		"typ":     services.TokenRefresh,
+		"user_id": user.ID,
+		"exp":     pair.refreshExpiry.Unix(),
+		"jti":     uuid.New().String(),
 	}
-	if err := h.db.Model(&session).Updates(updates).Error; err != nil {
-		c.JSON(500, models.ErrorResponse{
-			Success:   false,
-			Error:     "Failed to update session",
-			ErrorCode: "DATABASE_ERROR",
-		})
-		return
+	refresh, err := h.keys.Sign(refreshClaims)
+	if err != nil {
+		return pair, err
 	}
 
-	// 9. Return new access token
-	c.JSON(200, gin.H{
-		"access_token": newAccessTokenString,
-		"expires_in":   86400, // 24 hours
-	})
+	pair.access, pair.refresh = access, refresh
+	return pair, nil
+}
+
+// hashToken is how tokens are stored (SHA256).
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
 }
 
 func (h *AuthHandler) GetMe(c *gin.Context) {
@@ -332,13 +399,102 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 		return
 	}
 
-	c.JSON(200, UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Role:      user.Role,
-		FullName:  user.FullName,
-		Email:     user.Email,
-		IsActive:  user.IsActive,
-		CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	response := toUserResponse(&user)
+	response.Permissions = h.roles.Permissions(user.Role)
+	c.JSON(200, response)
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword lets users change their own password. Every other session
+// of the user is ended; the current one stays valid.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", c.GetString("user_id")).Error; err != nil {
+		c.JSON(404, models.ErrorResponse{
+			Success:   false,
+			Error:     "User not found",
+			ErrorCode: "USER_NOT_FOUND",
+		})
+		return
+	}
+
+	if ok, _ := h.passwords.Verify(user.PasswordHash, req.CurrentPassword); !ok {
+		c.JSON(401, models.ErrorResponse{
+			Success:   false,
+			Error:     "Current password is wrong",
+			ErrorCode: "INVALID_CREDENTIALS",
+		})
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		c.JSON(400, models.ErrorResponse{
+			Success:   false,
+			Error:     "The new password must differ from the current one",
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	if !checkPassword(c, h.passwords, req.NewPassword, user.Username) {
+		return
+	}
+	hashedPassword, err := h.passwords.Hash(req.NewPassword)
+	if err != nil {
+		c.JSON(500, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to hash password",
+			ErrorCode: "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	now := time.Now()
+	err = h.db.Model(&user).Updates(map[string]interface{}{
+		"password_hash":        hashedPassword,
+		"must_change_password": false,
+		"password_changed_at":  &now,
+	}).Error
+	if err != nil {
+		c.JSON(500, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to change password",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+	_, revokeErr := h.sessions.RevokeUser(user.ID, c.GetString("session_id"))
+
+	h.audit.LogUserChange(services.UserChangeEntry{
+		Action:          services.UserActionPasswordChange,
+		TargetUserID:    user.ID,
+		TargetUsername:  user.Username,
+		PerformedBy:     user.ID,
+		PerformedByName: user.Username,
+		ClientIP:        c.ClientIP(),
+		Changes:         map[string]services.FieldChange{"password": {After: "changed"}},
 	})
+
+	if revokeErr != nil {
+		c.JSON(500, models.ErrorResponse{
+			Success:   false,
+			Error:     "Password changed, but the other sessions could not be ended",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+	c.JSON(200, models.SuccessResponse{Success: true})
 }
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AutomationHandler manages control snapshots and macros.
+type AutomationHandler struct {
+	db      *gorm.DB
+	hub     *services.Hub
+	actions *services.ActionExecutor
+}
+
+func NewAutomationHandler(db *gorm.DB, hub *services.Hub, actions *services.ActionExecutor) *AutomationHandler {
+	return &AutomationHandler{db: db, hub: hub, actions: actions}
+}
+
+type SnapshotResponse struct {
+	ID        uint                    `json:"id"`
+	Name      string                  `json:"name"`
+	Controls  []services.ControlState `json:"controls"`
+	CreatedBy string                  `json:"created_by"`
+	CreatedAt time.Time               `json:"created_at"`
+}
+
+type MacroRequest struct {
+	Name        string            `json:"name" binding:"required"`
+	Description string            `json:"description"`
+	Actions     []services.Action `json:"actions" binding:"required"`
+}
+
+type MacroResponse struct {
+	ID          uint              `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Actions     []services.Action `json:"actions"`
+}
+
+// ActionFailedResponse lists every result when at least one action failed.
+type ActionFailedResponse struct {
+	models.ErrorResponse
+	Results []services.ActionResult `json:"results"`
+}
+
+func toSnapshotResponse(s *models.Snapshot) SnapshotResponse {
+	controls := []services.ControlState{}
+	json.Unmarshal([]byte(s.Controls), &controls)
+	return SnapshotResponse{
+		ID:        s.ID,
+		Name:      s.Name,
+		Controls:  controls,
+		CreatedBy: s.CreatedBy,
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+func toMacroResponse(m *models.Macro) MacroResponse {
+	actions, _ := services.DecodeActions(m.Actions)
+	return MacroResponse{
+		ID:          m.ID,
+		Name:        m.Name,
+		Description: m.Description,
+		Actions:     actions,
+	}
+}
+
+// --- Snapshots ---
+
+func (h *AutomationHandler) ListSnapshots(c *gin.Context) {
+	var snapshots []models.Snapshot
+	if err := h.db.Order("name").Find(&snapshots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch snapshots",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := make([]SnapshotResponse, 0, len(snapshots))
+	for i := range snapshots {
+		response = append(response, toSnapshotResponse(&snapshots[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CaptureSnapshot stores the current values of the selected controls (all
+// controls when control_ids is empty). Capturing an existing name replaces it.
+func (h *AutomationHandler) CaptureSnapshot(c *gin.Context) {
+	var req struct {
+		Name       string   `json:"name" binding:"required"`
+		ControlIDs []string `json:"control_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	states, err := h.actions.CaptureControls(req.ControlIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "HARDWARE_ERROR",
+		})
+		return
+	}
+	data, _ := json.Marshal(states)
+
+	var snapshot models.Snapshot
+	h.db.Where("name = ?", req.Name).First(&snapshot)
+	snapshot.Name = req.Name
+	snapshot.Controls = string(data)
+	snapshot.CreatedBy = c.GetString("username")
+	if err := h.db.Save(&snapshot).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to save snapshot",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, toSnapshotResponse(&snapshot))
+}
+
+func (h *AutomationHandler) DeleteSnapshot(c *gin.Context) {
+	if err := h.db.Unscoped().Delete(&models.Snapshot{}, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to delete snapshot",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
+}
+
+func (h *AutomationHandler) ApplySnapshot(c *gin.Context) {
+	var snapshot models.Snapshot
+	if err := h.db.First(&snapshot, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Snapshot not found",
+			ErrorCode: "NOT_FOUND",
+		})
+		return
+	}
+
+	h.runActions(c, "snapshots.apply", []services.Action{{Type: services.ActionApplySnapshot, Snapshot: snapshot.Name}})
+}
+
+// --- Macros ---
+
+func (h *AutomationHandler) ListMacros(c *gin.Context) {
+	var macros []models.Macro
+	if err := h.db.Order("name").Find(&macros).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch macros",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := make([]MacroResponse, 0, len(macros))
+	for i := range macros {
+		response = append(response, toMacroResponse(&macros[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AutomationHandler) CreateMacro(c *gin.Context) {
+	var req MacroRequest
+	if !h.bindMacro(c, &req) {
+		return
+	}
+
+	macro := models.Macro{
+		Name:        req.Name,
+		Description: req.Description,
+		Actions:     services.EncodeActions(req.Actions),
+	}
+	if err := h.db.Create(&macro).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to create macro (name might be taken)",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, toMacroResponse(&macro))
+}
+
+func (h *AutomationHandler) UpdateMacro(c *gin.Context) {
+	var macro models.Macro
+	if err := h.db.First(&macro, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Macro not found",
+			ErrorCode: "NOT_FOUND",
+		})
+		return
+	}
+
+	var req MacroRequest
+	if !h.bindMacro(c, &req) {
+		return
+	}
+
+	macro.Name = req.Name
+	macro.Description = req.Description
+	macro.Actions = services.EncodeActions(req.Actions)
+	if err := h.db.Save(&macro).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to update macro (name might be taken)",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, toMacroResponse(&macro))
+}
+
+func (h *AutomationHandler) DeleteMacro(c *gin.Context) {
+	if err := h.db.Unscoped().Delete(&models.Macro{}, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to delete macro",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
+}
+
+func (h *AutomationHandler) RunMacro(c *gin.Context) {
+	var macro models.Macro
+	if err := h.db.First(&macro, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Macro not found",
+			ErrorCode: "NOT_FOUND",
+		})
+		return
+	}
+
+	h.runActions(c, "macros.run", []services.Action{{Type: services.ActionRunMacro, Macro: macro.Name}})
+}
+
+func (h *AutomationHandler) bindMacro(c *gin.Context, req *MacroRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return false
+	}
+
+	if err := h.actions.Validate(req.Actions); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return false
+	}
+	return true
+}
+
+// runActions executes actions on behalf of the caller and broadcasts the
+// results as a command_executed event.
+func (h *AutomationHandler) runActions(c *gin.Context, command string, actions []services.Action) {
+	results := h.actions.Run(actions)
+
+	if h.hub != nil {
+		h.hub.BroadcastCommandExecuted(c.GetString("user_id"), c.GetString("username"), command, gin.H{"results": results})
+	}
+
+	for _, r := range results {
+		if !r.Success {
+			c.JSON(http.StatusBadGateway, ActionFailedResponse{
+				ErrorResponse: models.ErrorResponse{
+					Success:   false,
+					Error:     r.Error,
+					ErrorCode: "ACTION_FAILED",
+				},
+				Results: results,
+			})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true, Data: gin.H{"results": results}})
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type BackgroundMusicHandler struct {
+	db    *gorm.DB
+	music *services.BackgroundMusic
+}
+
+func NewBackgroundMusicHandler(db *gorm.DB, music *services.BackgroundMusic) *BackgroundMusicHandler {
+	return &BackgroundMusicHandler{db: db, music: music}
+}
+
+type BackgroundMusicSettingsRequest struct {
+	Enabled              bool    `json:"enabled"`
+	InterruptMode        string  `json:"interrupt_mode" binding:"required"`
+	DuckVolume           float64 `json:"duck_volume"`
+	PresetPauseMinutes   int     `json:"preset_pause_minutes"`
+	ServiceMarginMinutes int     `json:"service_margin_minutes"`
+}
+
+type BackgroundMusicWindowRequest struct {
+	Name      string  `json:"name" binding:"required"`
+	Weekdays  []int   `json:"weekdays"`
+	StartTime string  `json:"start_time" binding:"required"`
+	EndTime   string  `json:"end_time" binding:"required"`
+	SourceID  int     `json:"source_id"`
+	SongID    *int    `json:"song_id"`
+	ControlID string  `json:"control_id"`
+	Volume    float64 `json:"volume"`
+	Enabled   *bool   `json:"enabled"`
+}
+
+type BackgroundMusicWindowResponse struct {
+	ID        uint    `json:"id"`
+	Name      string  `json:"name"`
+	Weekdays  []int   `json:"weekdays"`
+	StartTime string  `json:"start_time"`
+	EndTime   string  `json:"end_time"`
+	SourceID  int     `json:"source_id"`
+	SongID    *int    `json:"song_id,omitempty"`
+	ControlID string  `json:"control_id"`
+	Volume    float64 `json:"volume"`
+	Enabled   bool    `json:"enabled"`
+}
+
+func toBackgroundMusicWindowResponse(w *models.BackgroundMusicWindow) BackgroundMusicWindowResponse {
+	weekdays := []int{}
+	for _, d := range services.SplitTags(w.Weekdays) {
+		if n, err := strconv.Atoi(d); err == nil {
+			weekdays = append(weekdays, n)
+		}
+	}
+	return BackgroundMusicWindowResponse{
+		ID:        w.ID,
+		Name:      w.Name,
+		Weekdays:  weekdays,
+		StartTime: w.StartTime,
+		EndTime:   w.EndTime,
+		SourceID:  w.SourceID,
+		SongID:    w.SongID,
+		ControlID: w.ControlID,
+		Volume:    w.Volume,
+		Enabled:   w.Enabled,
+	}
+}
+
+// GetBackgroundMusic returns settings, windows and the current state.
+func (h *BackgroundMusicHandler) GetBackgroundMusic(c *gin.Context) {
+	var windows []models.BackgroundMusicWindow
+	if err := h.db.Order("start_time").Find(&windows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch background music windows",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := make([]BackgroundMusicWindowResponse, 0, len(windows))
+	for i := range windows {
+		response = append(response, toBackgroundMusicWindowResponse(&windows[i]))
+	}
+
+	settings := h.music.Settings()
+	c.JSON(http.StatusOK, gin.H{
+		"settings": BackgroundMusicSettingsRequest{
+			Enabled:              settings.Enabled,
+			InterruptMode:        settings.InterruptMode,
+			DuckVolume:           settings.DuckVolume,
+			PresetPauseMinutes:   settings.PresetPauseMinutes,
+			ServiceMarginMinutes: settings.ServiceMarginMinutes,
+		},
+		"windows": response,
+		"state":   h.music.State(),
+	})
+}
+
+func (h *BackgroundMusicHandler) UpdateSettings(c *gin.Context) {
+	var req BackgroundMusicSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	settings := models.BackgroundMusicSettings{
+		Enabled:              req.Enabled,
+		InterruptMode:        req.InterruptMode,
+		DuckVolume:           req.DuckVolume,
+		PresetPauseMinutes:   req.PresetPauseMinutes,
+		ServiceMarginMinutes: req.ServiceMarginMinutes,
+	}
+	if err := h.music.SaveSettings(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, req)
+}
+
+func (h *BackgroundMusicHandler) CreateWindow(c *gin.Context) {
+	var req BackgroundMusicWindowRequest
+	if !bindBackgroundMusicWindow(c, &req) {
+		return
+	}
+
+	window := models.BackgroundMusicWindow{}
+	applyBackgroundMusicWindow(&window, &req)
+	if err := h.db.Create(&window).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to create window",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.music.Wake()
+	c.JSON(http.StatusCreated, toBackgroundMusicWindowResponse(&window))
+}
+
+func (h *BackgroundMusicHandler) UpdateWindow(c *gin.Context) {
+	var window models.BackgroundMusicWindow
+	if err := h.db.First(&window, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Window not found",
+			ErrorCode: "NOT_FOUND",
+		})
+		return
+	}
+
+	var req BackgroundMusicWindowRequest
+	if !bindBackgroundMusicWindow(c, &req) {
+		return
+	}
+
+	applyBackgroundMusicWindow(&window, &req)
+	if err := h.db.Save(&window).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to update window",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.music.Wake()
+	c.JSON(http.StatusOK, toBackgroundMusicWindowResponse(&window))
+}
+
+func (h *BackgroundMusicHandler) DeleteWindow(c *gin.Context) {
+	if err := h.db.Delete(&models.BackgroundMusicWindow{}, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to delete window",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.music.Wake()
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
+}
+
+func bindBackgroundMusicWindow(c *gin.Context, req *BackgroundMusicWindowRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return false
+	}
+
+	invalid := func(msg string) bool {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     msg,
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return false
+	}
+
+	if _, err := services.ParseClock(req.StartTime); err != nil {
+		return invalid(err.Error())
+	}
+	if _, err := services.ParseClock(req.EndTime); err != nil {
+		return invalid(err.Error())
+	}
+	if req.StartTime == req.EndTime {
+		return invalid("start_time and end_time must differ")
+	}
+	for _, d := range req.Weekdays {
+		if d < 0 || d > 6 {
+			return invalid("weekdays must be between 0 (Sunday) and 6 (Saturday)")
+		}
+	}
+	return true
+}
+
+func applyBackgroundMusicWindow(w *models.BackgroundMusicWindow, req *BackgroundMusicWindowRequest) {
+	days := make([]string, 0, len(req.Weekdays))
+	for _, d := range req.Weekdays {
+		days = append(days, strconv.Itoa(d))
+	}
+
+	w.Name = req.Name
+	w.Weekdays = services.JoinTags(days)
+	w.StartTime = req.StartTime
+	w.EndTime = req.EndTime
+	w.SourceID = req.SourceID
+	w.SongID = req.SongID
+	w.ControlID = req.ControlID
+	w.Volume = req.Volume
+	w.Enabled = req.Enabled == nil || *req.Enabled
+}
@@ -4,8 +4,13 @@ import (
 	"av-control/internal/hardware"
 	"av-control/internal/models"
 	"av-control/internal/services"
+	"errors"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -15,13 +20,25 @@ type Handler struct {
 	db       *gorm.DB
 	hwClient hardware.HardwareClient
 	hub      *services.Hub
+	recorder *services.RecordingSupervisor
+	namer    *services.RecordingNamer
+	presets  *services.PresetDirectory
+	guard    *services.PresetGuard
+	songs    *services.SongIndex
+	acl      *services.AccessControl
 }
 
-func NewHandler(db *gorm.DB, hwClient hardware.HardwareClient, hub *services.Hub) *Handler {
+func NewHandler(db *gorm.DB, hwClient hardware.HardwareClient, hub *services.Hub, recorder *services.RecordingSupervisor, namer *services.RecordingNamer, presets *services.PresetDirectory, guard *services.PresetGuard, songs *services.SongIndex, acl *services.AccessControl) *Handler {
 	return &Handler{
 		db:       db,
 		hwClient: hwClient,
 		hub:      hub,
+		recorder: recorder,
+		namer:    namer,
+		presets:  presets,
+		guard:    guard,
+		songs:    songs,
+		acl:      acl,
 	}
 }
 
@@ -43,14 +60,35 @@ func (h *Handler) respondSuccess(c *gin.Context, data interface{}) {
 	}
 }
 
+// allowed checks the access rules for the caller and answers 403 when the
+// resource is denied.
+func (h *Handler) allowed(c *gin.Context, resourceType, resourceID string) bool {
+	if h.acl.Allowed(c.GetString("user_id"), c.GetString("role"), resourceType, resourceID) {
+		return true
+	}
+	h.respondError(c, http.StatusForbidden, "You are not allowed to use this "+resourceType, "ACCESS_DENIED")
+	return false
+}
+
 // --- Presets ---
 
+// GetPresets returns the daemon presets merged with the server-side
+// metadata, hiding those not visible to the caller's role or denied to the
+// caller by an access rule.
 func (h *Handler) GetPresets(c *gin.Context) {
-	presets, err := h.hwClient.GetPresets()
+	presets, err := h.presets.List(c.GetString("role"))
 	if err != nil {
 		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
 		return
 	}
+
+	visible := presets.Presets[:0]
+	for _, p := range presets.Presets {
+		if h.acl.Allowed(c.GetString("user_id"), c.GetString("role"), services.ResourcePreset, p.ID) {
+			visible = append(visible, p)
+		}
+	}
+	presets.Presets = visible
 	h.respondSuccess(c, presets)
 }
 
@@ -63,27 +101,85 @@ func (h *Handler) GetCurrentPreset(c *gin.Context) {
 	h.respondSuccess(c, preset)
 }
 
+// ConfirmationRequiredResponse is returned (409) when a guard rule blocks
+// a preset load. Repeating the request with confirmation_token proceeds.
+type ConfirmationRequiredResponse struct {
+	models.ErrorResponse
+	Reasons           []services.GuardReason `json:"reasons"`
+	ConfirmationToken string                 `json:"confirmation_token"`
+	ExpiresAt         time.Time              `json:"expires_at"`
+}
+
 // LoadPreset
 func (h *Handler) LoadPreset(c *gin.Context) {
 	var req struct {
-		ID string `json:"id" binding:"required"`
+		ID                string `json:"id" binding:"required"`
+		ConfirmationToken string `json:"confirmation_token"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.respondError(c, http.StatusBadRequest, err.Error(), "INVALID_REQUEST")
 		return
 	}
+	if !h.allowed(c, services.ResourcePreset, req.ID) {
+		return
+	}
+	// A preset hidden from the role's list cannot be loaded by ID either
+	visible, err := h.presets.Visible(req.ID, c.GetString("role"))
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err.Error(), "DATABASE_ERROR")
+		return
+	}
+	if !visible {
+		h.respondError(c, http.StatusForbidden, "You are not allowed to use this "+services.ResourcePreset, "ACCESS_DENIED")
+		return
+	}
+
+	userID := c.GetString("user_id")
+	username := c.GetString("username")
+
+	now := time.Now()
+	if !h.guard.Confirm(req.ConfirmationToken, req.ID, userID, now) {
+		if reasons := h.guard.Check(now); len(reasons) > 0 {
+			token, expiresAt := h.guard.Issue(req.ID, userID, now)
+			c.JSON(http.StatusConflict, ConfirmationRequiredResponse{
+				ErrorResponse: models.ErrorResponse{
+					Success:   false,
+					Error:     "Loading this preset may interrupt a live service",
+					ErrorCode: "CONFIRMATION_REQUIRED",
+				},
+				Reasons:           reasons,
+				ConfirmationToken: token,
+				ExpiresAt:         expiresAt,
+			})
+			return
+		}
+	}
 
 	if err := h.hwClient.LoadPreset(req.ID); err != nil {
 		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
 		return
 	}
 
-	userID := c.GetString("user_id")
-	username := c.GetString("username")
+	// Reapply local overrides the preset load has reset. The hooks run in
+	// the background and their results are broadcast when done.
+	hooksPending := h.presets.AfterLoad(req.ID, func(results []services.ActionResult) {
+		if h.hub != nil {
+			h.hub.BroadcastCommandExecuted(userID, username, "presets.hooks", gin.H{"id": req.ID, "hooks": results})
+		}
+	})
+
 	if h.hub != nil {
-		h.hub.BroadcastCommandExecuted(userID, username, "presets.load", gin.H{"id": req.ID})
+		payload := gin.H{"id": req.ID}
+		if hooksPending {
+			payload["hooks_pending"] = true
+		}
+		h.hub.BroadcastCommandExecuted(userID, username, "presets.load", payload)
 	}
 
+	if hooksPending {
+		h.respondSuccess(c, models.SuccessResponse{Success: true, Data: gin.H{"hooks_pending": true}})
+		return
+	}
 	h.respondSuccess(c, nil)
 }
 
@@ -95,7 +191,14 @@ func (h *Handler) GetSources(c *gin.Context) {
 		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
 		return
 	}
-	h.respondSuccess(c, sources)
+
+	visible := make([]models.Source, 0, len(sources.Sources))
+	for _, s := range sources.Sources {
+		if h.acl.Allowed(c.GetString("user_id"), c.GetString("role"), services.ResourceSource, strconv.Itoa(s.ID)) {
+			visible = append(visible, s)
+		}
+	}
+	h.respondSuccess(c, models.SourcesResponse{Sources: visible})
 }
 
 // SelectSource
@@ -112,11 +215,15 @@ func (h *Handler) SelectSource(c *gin.Context) {
 		h.respondError(c, http.StatusBadRequest, "source ID is required", "INVALID_REQUEST")
 		return
 	}
+	if !h.allowed(c, services.ResourceSource, strconv.Itoa(*req.ID)) {
+		return
+	}
 
 	if err := h.hwClient.SelectSource(*req.ID); err != nil {
 		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
 		return
 	}
+	h.songs.SourceSelected(*req.ID)
 
 	userID := c.GetString("user_id")
 	username := c.GetString("username")
@@ -127,8 +234,9 @@ func (h *Handler) SelectSource(c *gin.Context) {
 	h.respondSuccess(c, nil)
 }
 
+// GetSongs returns the current source's songs (cached, sorted by name)
 func (h *Handler) GetSongs(c *gin.Context) {
-	songs, err := h.hwClient.GetSongs()
+	songs, err := h.songs.Songs()
 	if err != nil {
 		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
 		return
@@ -136,6 +244,58 @@ func (h *Handler) GetSongs(c *gin.Context) {
 	h.respondSuccess(c, songs)
 }
 
+// SearchSongs searches the current source by title (accent-insensitive,
+// tolerant to typos) and/or tag, with pagination
+func (h *Handler) SearchSongs(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+
+	result, err := h.songs.Search(services.SongQuery{
+		Query:    c.Query("q"),
+		Tag:      c.Query("tag"),
+		Page:     page,
+		PageSize: pageSize,
+	})
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
+		return
+	}
+	h.respondSuccess(c, result)
+}
+
+// RefreshSongs drops the cached song lists
+func (h *Handler) RefreshSongs(c *gin.Context) {
+	h.songs.Invalidate()
+	h.respondSuccess(c, nil)
+}
+
+func (h *Handler) GetSongTags(c *gin.Context) {
+	tags, err := h.songs.Tags()
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, "Failed to fetch tags", "DATABASE_ERROR")
+		return
+	}
+	h.respondSuccess(c, gin.H{"tags": tags})
+}
+
+// SetSongTags replaces the tags of a song (identified by name)
+func (h *Handler) SetSongTags(c *gin.Context) {
+	var req struct {
+		SongName string   `json:"song_name" binding:"required"`
+		Tags     []string `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err.Error(), "INVALID_REQUEST")
+		return
+	}
+
+	tags, err := h.songs.SetTags(req.SongName, req.Tags)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, "Failed to save tags", "DATABASE_ERROR")
+		return
+	}
+	h.respondSuccess(c, gin.H{"song_name": req.SongName, "tags": tags})
+}
+
 // SelectSong
 func (h *Handler) SelectSong(c *gin.Context) {
 	var req struct {
@@ -257,6 +417,16 @@ func (h *Handler) SetRepeatMode(c *gin.Context) {
 	// DEBUG LOG
 	log.Printf("🔁 [REPEAT] Received mode: %s", req.Mode)
 
+	caps, err := h.hwClient.GetPlayerCapabilities()
+	if err != nil {
+		h.respondError(c, http.StatusBadGateway, "Cannot read the player capabilities: "+err.Error(), "HARDWARE_ERROR")
+		return
+	}
+	if !containsString(caps.RepeatModes, req.Mode) {
+		h.respondError(c, http.StatusBadRequest, "Unsupported repeat mode (allowed: "+strings.Join(caps.RepeatModes, ", ")+")", "INVALID_REPEAT_MODE")
+		return
+	}
+
 	if err := h.hwClient.SetRepeatMode(req.Mode); err != nil {
 		log.Printf("❌ [REPEAT] Hardware error: %v", err)
 		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
@@ -274,6 +444,77 @@ func (h *Handler) SetRepeatMode(c *gin.Context) {
 	h.respondSuccess(c, nil)
 }
 
+// Seek moves the current track to a position in seconds
+func (h *Handler) Seek(c *gin.Context) {
+	var req struct {
+		Position *int `json:"position" binding:"required,min=0"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err.Error(), "INVALID_REQUEST")
+		return
+	}
+
+	emulated, err := services.SeekPlayer(h.hwClient, *req.Position)
+	if errors.Is(err, services.ErrInvalidSeekPosition) {
+		h.respondError(c, http.StatusBadRequest, err.Error(), "INVALID_REQUEST")
+		return
+	}
+	if errors.Is(err, hardware.ErrNotSupported) {
+		h.respondError(c, http.StatusNotImplemented, "The player can only restart the current track (position 0)", "NOT_SUPPORTED")
+		return
+	}
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
+		return
+	}
+
+	userID := c.GetString("user_id")
+	username := c.GetString("username")
+	if h.hub != nil {
+		h.hub.BroadcastCommandExecuted(userID, username, "player.seek", gin.H{"position": *req.Position, "emulated": emulated})
+	}
+
+	h.respondSuccess(c, models.SuccessResponse{Success: true, Data: gin.H{"position": *req.Position, "emulated": emulated}})
+}
+
+// SetShuffle
+func (h *Handler) SetShuffle(c *gin.Context) {
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err.Error(), "INVALID_REQUEST")
+		return
+	}
+
+	err := h.hwClient.SetShuffle(*req.Enabled)
+	if errors.Is(err, hardware.ErrNotSupported) {
+		h.respondError(c, http.StatusNotImplemented, "The player does not support shuffle", "NOT_SUPPORTED")
+		return
+	}
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
+		return
+	}
+
+	userID := c.GetString("user_id")
+	username := c.GetString("username")
+	if h.hub != nil {
+		h.hub.BroadcastCommandExecuted(userID, username, "player.shuffle", gin.H{"enabled": *req.Enabled})
+	}
+
+	h.respondSuccess(c, nil)
+}
+
+func (h *Handler) GetPlayerCapabilities(c *gin.Context) {
+	caps, err := h.hwClient.GetPlayerCapabilities()
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
+		return
+	}
+	h.respondSuccess(c, caps)
+}
+
 func (h *Handler) GetPlayerStatus(c *gin.Context) {
 	status, err := h.hwClient.GetPlayerStatus()
 	if err != nil {
@@ -287,44 +528,90 @@ func (h *Handler) GetPlayerStatus(c *gin.Context) {
 
 func (h *Handler) StartRecording(c *gin.Context) {
 	var req struct {
-		Filename string `json:"filename"`
+		Filename           string     `json:"filename"`
+		Template           string     `json:"template"`
+		Event              string     `json:"event"`
+		MaxDurationMinutes int        `json:"max_duration_minutes" binding:"min=0"`
+		StopAt             *time.Time `json:"stop_at"`
 	}
 
-	_ = c.ShouldBindJSON(&req)
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.respondError(c, http.StatusBadRequest, err.Error(), "INVALID_REQUEST")
+		return
+	}
 
 	// DEBUG LOG
 	log.Printf("🎥 [RECORDER] Received filename: '%s' (len=%d)", req.Filename, len(req.Filename))
 
-	actualFilename, err := h.hwClient.StartRecording(req.Filename)
+	userID := c.GetString("user_id")
+	username := c.GetString("username")
+
+	// Apply naming policy (templates, sanitization, collisions)
+	filename, err := h.namer.Resolve(services.NameRequest{
+		Filename: req.Filename,
+		Template: req.Template,
+		Event:    req.Event,
+		Username: username,
+		Now:      time.Now(),
+	})
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err.Error(), "INVALID_REQUEST")
+		return
+	}
+
+	info, err := h.recorder.StartRecording(services.StartRecordingOptions{
+		Filename:    filename,
+		UserID:      userID,
+		Username:    username,
+		MaxDuration: time.Duration(req.MaxDurationMinutes) * time.Minute,
+		StopAt:      req.StopAt,
+	})
+	if errors.Is(err, services.ErrInvalidStopTime) {
+		h.respondError(c, http.StatusBadRequest, err.Error(), "INVALID_REQUEST")
+		return
+	}
+	if errors.Is(err, services.ErrAlreadyRecording) {
+		h.respondError(c, http.StatusConflict, err.Error(), "ALREADY_RECORDING")
+		return
+	}
+	if errors.Is(err, services.ErrInsufficientStorage) {
+		h.respondError(c, http.StatusInsufficientStorage, err.Error(), "INSUFFICIENT_STORAGE")
+		return
+	}
 	if err != nil {
 		log.Printf("❌ [RECORDER] Hardware error: %v", err)
 		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
 		return
 	}
 
-	log.Printf("✅ [RECORDER] Recording started: %s", actualFilename)
+	log.Printf("✅ [RECORDER] Recording started: %s", info.Filename)
 
 	// Broadcast command execution
-	userID := c.GetString("user_id")
-	username := c.GetString("username")
 	if h.hub != nil {
-		h.hub.BroadcastCommandExecuted(userID, username, "recorder.start", gin.H{"filename": actualFilename})
+		h.hub.BroadcastCommandExecuted(userID, username, "recorder.start", info)
 	}
 
-	h.respondSuccess(c, gin.H{"filename": actualFilename})
+	h.respondSuccess(c, info)
 }
 
 func (h *Handler) StopRecording(c *gin.Context) {
-	if err := h.hwClient.StopRecording(); err != nil {
+	userID := c.GetString("user_id")
+	username := c.GetString("username")
+
+	rec, err := h.recorder.StopRecording(userID)
+	if err != nil {
 		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
 		return
 	}
 
+	var payload interface{}
+	if rec != nil {
+		payload = gin.H{"recording_id": rec.ID, "filename": rec.Filename, "duration_seconds": rec.DurationSeconds}
+	}
+
 	// Broadcast command execution
-	userID := c.GetString("user_id")
-	username := c.GetString("username")
 	if h.hub != nil {
-		h.hub.BroadcastCommandExecuted(userID, username, "recorder.stop", nil)
+		h.hub.BroadcastCommandExecuted(userID, username, "recorder.stop", payload)
 	}
 
 	h.respondSuccess(c, nil)
@@ -336,6 +623,10 @@ func (h *Handler) GetRecorderStatus(c *gin.Context) {
 		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
 		return
 	}
+
+	if current := h.recorder.Current(); current != nil {
+		status.StopAt = current.StopAt
+	}
 	h.respondSuccess(c, status)
 }
 
@@ -347,7 +638,14 @@ func (h *Handler) GetControls(c *gin.Context) {
 		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
 		return
 	}
-	h.respondSuccess(c, controls)
+
+	visible := make([]models.Control, 0, len(controls.Controls))
+	for _, ctrl := range controls.Controls {
+		if h.acl.Allowed(c.GetString("user_id"), c.GetString("role"), services.ResourceControl, strconv.Itoa(ctrl.ID)) {
+			visible = append(visible, ctrl)
+		}
+	}
+	h.respondSuccess(c, models.ControlsResponse{Controls: visible})
 }
 
 func (h *Handler) GetControlValue(c *gin.Context) {
@@ -357,6 +655,10 @@ func (h *Handler) GetControlValue(c *gin.Context) {
 		return
 	}
 
+	if !h.allowed(c, services.ResourceControl, controlID) {
+		return
+	}
+
 	val, err := h.hwClient.GetControlValue(controlID)
 	if err != nil {
 		h.respondError(c, http.StatusInternalServerError, err.Error(), "HARDWARE_ERROR")
@@ -373,6 +675,10 @@ func (h *Handler) GetControlVolume(c *gin.Context) {
 		return
 	}
 
+	if !h.allowed(c, services.ResourceControl, controlID) {
+		return
+	}
+
 	// Cast to RealHardwareClient to access internal methods
 	realClient, ok := h.hwClient.(*hardware.RealHardwareClient)
 	if !ok {
@@ -401,6 +707,10 @@ func (h *Handler) GetControlMute(c *gin.Context) {
 		return
 	}
 
+	if !h.allowed(c, services.ResourceControl, controlID) {
+		return
+	}
+
 	// Cast to RealHardwareClient
 	realClient, ok := h.hwClient.(*hardware.RealHardwareClient)
 	if !ok {
@@ -428,6 +738,10 @@ func (h *Handler) SetControlValue(c *gin.Context) {
 		return
 	}
 
+	if !h.allowed(c, services.ResourceControl, controlID) {
+		return
+	}
+
 	var req struct {
 		Value interface{} `json:"value" binding:"required"`
 	}
@@ -461,3 +775,12 @@ func (h *Handler) GetSystemStatus(c *gin.Context) {
 	}
 	h.respondSuccess(c, status)
 }
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
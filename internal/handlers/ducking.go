@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DuckingHandler struct {
+	db     *gorm.DB
+	ducker *services.Ducker
+}
+
+func NewDuckingHandler(db *gorm.DB, ducker *services.Ducker) *DuckingHandler {
+	return &DuckingHandler{db: db, ducker: ducker}
+}
+
+type DuckingRuleRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	VoiceControls []string `json:"voice_controls" binding:"required,min=1"`
+	MusicControls []string `json:"music_controls" binding:"required,min=1"`
+	DuckBy        float64  `json:"duck_by" binding:"gt=0"`
+	RampMs        int      `json:"ramp_ms" binding:"min=0,max=10000"`
+	HoldMs        int      `json:"hold_ms" binding:"min=0,max=60000"`
+	Enabled       *bool    `json:"enabled"`
+}
+
+type DuckingRuleResponse struct {
+	ID            uint     `json:"id"`
+	Name          string   `json:"name"`
+	VoiceControls []string `json:"voice_controls"`
+	MusicControls []string `json:"music_controls"`
+	DuckBy        float64  `json:"duck_by"`
+	RampMs        int      `json:"ramp_ms"`
+	HoldMs        int      `json:"hold_ms"`
+	Enabled       bool     `json:"enabled"`
+}
+
+func toDuckingRuleResponse(r *models.DuckingRule) DuckingRuleResponse {
+	return DuckingRuleResponse{
+		ID:            r.ID,
+		Name:          r.Name,
+		VoiceControls: services.SplitTags(r.VoiceControls),
+		MusicControls: services.SplitTags(r.MusicControls),
+		DuckBy:        r.DuckBy,
+		RampMs:        r.RampMs,
+		HoldMs:        r.HoldMs,
+		Enabled:       r.Enabled,
+	}
+}
+
+// GetDucking lists the rules and the live state of the enabled ones.
+func (h *DuckingHandler) GetDucking(c *gin.Context) {
+	var rules []models.DuckingRule
+	if err := h.db.Order("name").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch ducking rules",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := make([]DuckingRuleResponse, 0, len(rules))
+	for i := range rules {
+		response = append(response, toDuckingRuleResponse(&rules[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": response, "state": h.ducker.States()})
+}
+
+func (h *DuckingHandler) CreateRule(c *gin.Context) {
+	var req DuckingRuleRequest
+	if !bindDuckingRule(c, &req) {
+		return
+	}
+
+	rule := models.DuckingRule{}
+	applyDuckingRule(&rule, &req)
+	if err := h.db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to create ducking rule",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.ducker.Reload()
+	c.JSON(http.StatusCreated, toDuckingRuleResponse(&rule))
+}
+
+func (h *DuckingHandler) UpdateRule(c *gin.Context) {
+	var rule models.DuckingRule
+	if err := h.db.First(&rule, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Ducking rule not found",
+			ErrorCode: "NOT_FOUND",
+		})
+		return
+	}
+
+	var req DuckingRuleRequest
+	if !bindDuckingRule(c, &req) {
+		return
+	}
+
+	applyDuckingRule(&rule, &req)
+	if err := h.db.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to update ducking rule",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.ducker.Reload()
+	c.JSON(http.StatusOK, toDuckingRuleResponse(&rule))
+}
+
+func (h *DuckingHandler) DeleteRule(c *gin.Context) {
+	if err := h.db.Delete(&models.DuckingRule{}, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to delete ducking rule",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.ducker.Reload()
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
+}
+
+func bindDuckingRule(c *gin.Context, req *DuckingRuleRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return false
+	}
+	return true
+}
+
+func applyDuckingRule(r *models.DuckingRule, req *DuckingRuleRequest) {
+	r.Name = req.Name
+	r.VoiceControls = services.JoinTags(req.VoiceControls)
+	r.MusicControls = services.JoinTags(req.MusicControls)
+	r.DuckBy = req.DuckBy
+	r.RampMs = req.RampMs
+	r.HoldMs = req.HoldMs
+	r.Enabled = req.Enabled == nil || *req.Enabled
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EmergencyHandler exposes the emergency mute-all and its restore.
+type EmergencyHandler struct {
+	emergency *services.Emergency
+	hub       *services.Hub
+}
+
+func NewEmergencyHandler(emergency *services.Emergency, hub *services.Hub) *EmergencyHandler {
+	return &EmergencyHandler{emergency: emergency, hub: hub}
+}
+
+func (h *EmergencyHandler) GetState(c *gin.Context) {
+	c.JSON(http.StatusOK, h.emergency.State())
+}
+
+// MuteAll is open to every authenticated user: whoever hears the feedback
+// must be able to stop it.
+func (h *EmergencyHandler) MuteAll(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	c.ShouldBindJSON(&req)
+
+	state, err := h.emergency.MuteAll(c.GetString("username"), req.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "HARDWARE_ERROR",
+		})
+		return
+	}
+
+	h.hub.BroadcastCommandExecuted(c.GetString("user_id"), c.GetString("username"), "emergency.mute_all", gin.H{"reason": req.Reason})
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true, Data: state})
+}
+
+func (h *EmergencyHandler) Restore(c *gin.Context) {
+	state, err := h.emergency.Restore(c.GetString("username"))
+	if errors.Is(err, services.ErrNoEmergency) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "NO_EMERGENCY",
+		})
+		return
+	}
+
+	h.hub.BroadcastCommandExecuted(c.GetString("user_id"), c.GetString("username"), "emergency.restore", nil)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, ActionFailedResponse{
+			ErrorResponse: models.ErrorResponse{
+				Success:   false,
+				Error:     "Lock lifted, but some controls were not restored: " + err.Error(),
+				ErrorCode: "RESTORE_INCOMPLETE",
+			},
+			Results: state.Results,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true, Data: state})
+}
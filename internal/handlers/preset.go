@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PresetHandler manages the server-side preset metadata overlay.
+type PresetHandler struct {
+	presets *services.PresetDirectory
+}
+
+func NewPresetHandler(presets *services.PresetDirectory) *PresetHandler {
+	return &PresetHandler{presets: presets}
+}
+
+type PresetMetaRequest struct {
+	DisplayName  string   `json:"display_name"`
+	Description  string   `json:"description"`
+	Color        string   `json:"color"`
+	Icon         string   `json:"icon"`
+	SortOrder    int      `json:"sort_order"`
+	VisibleRoles []string `json:"visible_roles"`
+	Favorite     bool     `json:"favorite"`
+}
+
+type PresetMetaResponse struct {
+	PresetID     string   `json:"preset_id"`
+	DisplayName  string   `json:"display_name"`
+	Description  string   `json:"description"`
+	Color        string   `json:"color"`
+	Icon         string   `json:"icon"`
+	SortOrder    int      `json:"sort_order"`
+	VisibleRoles []string `json:"visible_roles"`
+	Favorite     bool     `json:"favorite"`
+}
+
+func toPresetMetaResponse(meta *models.PresetMeta) PresetMetaResponse {
+	return PresetMetaResponse{
+		PresetID:     meta.PresetID,
+		DisplayName:  meta.DisplayName,
+		Description:  meta.Description,
+		Color:        meta.Color,
+		Icon:         meta.Icon,
+		SortOrder:    meta.SortOrder,
+		VisibleRoles: services.SplitTags(meta.VisibleRoles),
+		Favorite:     meta.Favorite,
+	}
+}
+
+func (h *PresetHandler) ListPresetMeta(c *gin.Context) {
+	metas, err := h.presets.ListMeta()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch preset metadata",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := make([]PresetMetaResponse, 0, len(metas))
+	for i := range metas {
+		response = append(response, toPresetMetaResponse(&metas[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *PresetHandler) UpdatePresetMeta(c *gin.Context) {
+	var req PresetMetaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	meta := models.PresetMeta{
+		PresetID:     c.Param("id"),
+		DisplayName:  req.DisplayName,
+		Description:  req.Description,
+		Color:        req.Color,
+		Icon:         req.Icon,
+		SortOrder:    req.SortOrder,
+		VisibleRoles: services.JoinTags(req.VisibleRoles),
+		Favorite:     req.Favorite,
+	}
+	if err := h.presets.SaveMeta(&meta); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to save preset metadata",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, toPresetMetaResponse(&meta))
+}
+
+func (h *PresetHandler) DeletePresetMeta(c *gin.Context) {
+	if err := h.presets.DeleteMeta(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to delete preset metadata",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
+}
+
+type PresetHooksRequest struct {
+	Enabled *bool             `json:"enabled"`
+	Actions []services.Action `json:"actions" binding:"required"`
+}
+
+type PresetHooksResponse struct {
+	PresetID string            `json:"preset_id"`
+	Enabled  bool              `json:"enabled"`
+	Actions  []services.Action `json:"actions"`
+}
+
+func (h *PresetHandler) GetPresetHooks(c *gin.Context) {
+	hook, actions, err := h.presets.Hooks(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch preset hooks",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, PresetHooksResponse{PresetID: hook.PresetID, Enabled: hook.Enabled, Actions: actions})
+}
+
+func (h *PresetHandler) UpdatePresetHooks(c *gin.Context) {
+	var req PresetHooksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	hook, err := h.presets.SaveHooks(c.Param("id"), req.Actions, enabled)
+	if errors.Is(err, services.ErrInvalidAction) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to save preset hooks",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, PresetHooksResponse{PresetID: hook.PresetID, Enabled: hook.Enabled, Actions: req.Actions})
+}
+
+func (h *PresetHandler) DeletePresetHooks(c *gin.Context) {
+	if err := h.presets.DeleteHooks(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to delete preset hooks",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
+}
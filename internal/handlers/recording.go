@@ -0,0 +1,425 @@
+package handlers
+
+import (
+	"av-control/internal/hardware"
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RecordingHandler struct {
+	catalog       *services.RecordingCatalog
+	storage       *services.StorageGuard
+	archiver      *services.Archiver
+	hwClient      hardware.HardwareClient
+	recordingsDir string
+}
+
+func NewRecordingHandler(catalog *services.RecordingCatalog, storage *services.StorageGuard, archiver *services.Archiver, hwClient hardware.HardwareClient, recordingsDir string) *RecordingHandler {
+	return &RecordingHandler{
+		catalog:       catalog,
+		storage:       storage,
+		archiver:      archiver,
+		hwClient:      hwClient,
+		recordingsDir: recordingsDir,
+	}
+}
+
+type RecordingResponse struct {
+	ID              uint                      `json:"id"`
+	Filename        string                    `json:"filename"`
+	StartedAt       string                    `json:"started_at"`
+	StoppedAt       string                    `json:"stopped_at,omitempty"`
+	DurationSeconds int                       `json:"duration_seconds"`
+	StartedBy       string                    `json:"started_by,omitempty"`
+	StartedByName   string                    `json:"started_by_name,omitempty"`
+	StoppedBy       string                    `json:"stopped_by,omitempty"`
+	PresetID        string                    `json:"preset_id,omitempty"`
+	StopReason      string                    `json:"stop_reason,omitempty"`
+	Tags            []string                  `json:"tags"`
+	Notes           string                    `json:"notes"`
+	FilePurged      bool                      `json:"file_purged"`
+	Archive         *RecordingArchiveResponse `json:"archive,omitempty"`
+}
+
+type RecordingArchiveResponse struct {
+	Status     string `json:"status"`
+	Target     string `json:"target"`
+	Attempts   int    `json:"attempts"`
+	Checksum   string `json:"checksum,omitempty"`
+	Error      string `json:"error,omitempty"`
+	ArchivedAt string `json:"archived_at,omitempty"`
+}
+
+type UpdateRecordingRequest struct {
+	Tags  []string `json:"tags"`
+	Notes *string  `json:"notes"`
+}
+
+func toRecordingResponse(r *models.Recording) RecordingResponse {
+	resp := RecordingResponse{
+		ID:              r.ID,
+		Filename:        r.Filename,
+		StartedAt:       r.StartedAt.Format(time.RFC3339),
+		DurationSeconds: r.DurationSeconds,
+		StartedBy:       r.StartedBy,
+		StartedByName:   r.StartedByName,
+		StoppedBy:       r.StoppedBy,
+		PresetID:        r.PresetID,
+		StopReason:      r.StopReason,
+		Tags:            services.SplitTags(r.Tags),
+		Notes:           r.Notes,
+		FilePurged:      r.FilePurgedAt != nil,
+	}
+	if r.StoppedAt != nil {
+		resp.StoppedAt = r.StoppedAt.Format(time.RFC3339)
+	}
+	if r.ArchiveStatus != "" {
+		resp.Archive = &RecordingArchiveResponse{
+			Status:   r.ArchiveStatus,
+			Target:   r.ArchiveTarget,
+			Attempts: r.ArchiveAttempts,
+			Checksum: r.ArchiveChecksum,
+			Error:    r.ArchiveError,
+		}
+		if r.ArchivedAt != nil {
+			resp.Archive.ArchivedAt = r.ArchivedAt.Format(time.RFC3339)
+		}
+	}
+	return resp
+}
+
+// parsePagination reads page/page_size query parameters (defaults 1 and 50).
+func parsePagination(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if pageSize < 1 || pageSize > 200 {
+		pageSize = 50
+	}
+	return page, pageSize
+}
+
+// parseTimeQuery accepts RFC3339 timestamps or plain dates (YYYY-MM-DD).
+func parseTimeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, true
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return &t, true
+	}
+	return nil, false
+}
+
+func (h *RecordingHandler) recordingID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     "Invalid recording ID",
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *RecordingHandler) ListRecordings(c *gin.Context) {
+	from, okFrom := parseTimeQuery(c, "from")
+	to, okTo := parseTimeQuery(c, "to")
+	if !okFrom || !okTo {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     "Invalid date (use YYYY-MM-DD or RFC3339)",
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+	recs, total, err := h.catalog.List(services.RecordingFilter{
+		Query:    c.Query("q"),
+		Tag:      c.Query("tag"),
+		UserID:   c.Query("user_id"),
+		From:     from,
+		To:       to,
+		Page:     page,
+		PageSize: pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch recordings",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := make([]RecordingResponse, 0, len(recs))
+	for i := range recs {
+		response = append(response, toRecordingResponse(&recs[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recordings": response,
+		"total":      total,
+		"page":       page,
+		"page_size":  pageSize,
+	})
+}
+
+func (h *RecordingHandler) GetRecording(c *gin.Context) {
+	id, ok := h.recordingID(c)
+	if !ok {
+		return
+	}
+
+	rec, err := h.catalog.Get(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Recording not found",
+			ErrorCode: "NOT_FOUND",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, toRecordingResponse(rec))
+}
+
+func (h *RecordingHandler) UpdateRecording(c *gin.Context) {
+	id, ok := h.recordingID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateRecordingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	rec, err := h.catalog.UpdateMetadata(id, req.Tags, req.Notes)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Recording not found",
+			ErrorCode: "NOT_FOUND",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, toRecordingResponse(rec))
+}
+
+// DownloadRecording streams the file from the recordings directory, or from
+// the daemon when it exposes recorded files. Range requests are supported
+// on both paths.
+func (h *RecordingHandler) DownloadRecording(c *gin.Context) {
+	id, ok := h.recordingID(c)
+	if !ok {
+		return
+	}
+
+	rec, err := h.catalog.Get(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Recording not found",
+			ErrorCode: "NOT_FOUND",
+		})
+		return
+	}
+
+	// Never trust the stored name as a path
+	name := filepath.Base(rec.Filename)
+	c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+
+	if h.recordingsDir != "" {
+		if f, err := os.Open(filepath.Join(h.recordingsDir, name)); err == nil {
+			defer f.Close()
+			if info, err := f.Stat(); err == nil && !info.IsDir() {
+				http.ServeContent(c.Writer, c.Request, name, info.ModTime(), f)
+				return
+			}
+		}
+	}
+
+	source, ok := h.hwClient.(hardware.RecordingFileSource)
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Recording file not available",
+			ErrorCode: "FILE_NOT_AVAILABLE",
+		})
+		return
+	}
+
+	resp, err := source.OpenRecording(name, c.GetHeader("Range"))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "FILE_NOT_AVAILABLE",
+		})
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, header := range []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified"} {
+		if value := resp.Header.Get(header); value != "" {
+			c.Header(header, value)
+		}
+	}
+	c.Status(resp.StatusCode)
+	io.Copy(c.Writer, resp.Body)
+}
+
+// GetStorage reports disk usage and the retention policy.
+func (h *RecordingHandler) GetStorage(c *gin.Context) {
+	if !h.storage.Enabled() {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Recordings directory not configured",
+			ErrorCode: "NOT_CONFIGURED",
+		})
+		return
+	}
+
+	status, err := h.storage.Status()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "STORAGE_ERROR",
+		})
+		return
+	}
+
+	policy := h.storage.Policy()
+	c.JSON(http.StatusOK, gin.H{
+		"storage": status,
+		"policy": gin.H{
+			"keep_days":       policy.KeepDays,
+			"max_total_bytes": policy.MaxTotalBytes,
+			"keep_tagged":     policy.KeepTagged,
+			"min_free_bytes":  policy.MinFreeBytes,
+			"warn_free_bytes": policy.WarnFreeBytes,
+		},
+	})
+}
+
+// RunRetention applies the retention policy now. With ?dry_run=true it only
+// lists the files that would be deleted.
+func (h *RecordingHandler) RunRetention(c *gin.Context) {
+	if !h.storage.Enabled() {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Recordings directory not configured",
+			ErrorCode: "NOT_CONFIGURED",
+		})
+		return
+	}
+
+	dryRun := c.Query("dry_run") == "true"
+	candidates, err := h.storage.Enforce(dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "STORAGE_ERROR",
+		})
+		return
+	}
+
+	if candidates == nil {
+		candidates = []services.RetentionCandidate{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run": dryRun,
+		"deleted": candidates,
+	})
+}
+
+// ArchiveRecording queues a recording for (re)archival.
+func (h *RecordingHandler) ArchiveRecording(c *gin.Context) {
+	id, ok := h.recordingID(c)
+	if !ok {
+		return
+	}
+
+	if !h.archiver.Enabled() {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Archiving not configured",
+			ErrorCode: "NOT_CONFIGURED",
+		})
+		return
+	}
+
+	rec, err := h.catalog.Get(id)
+	if err != nil || rec.StoppedAt == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Recording not found or still in progress",
+			ErrorCode: "NOT_FOUND",
+		})
+		return
+	}
+
+	if err := h.archiver.Archive(id); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse{Success: true})
+}
+
+// ClearArchive gives up on a failed archival so retention may delete the file.
+func (h *RecordingHandler) ClearArchive(c *gin.Context) {
+	id, ok := h.recordingID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.archiver.Clear(id); err != nil {
+		if errors.Is(err, services.ErrArchiveNotFailed) {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Success:   false,
+				Error:     "Recording archival has not failed",
+				ErrorCode: "ARCHIVE_NOT_FAILED",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
+}
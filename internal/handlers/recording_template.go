@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RecordingTemplateHandler struct {
+	db    *gorm.DB
+	namer *services.RecordingNamer
+}
+
+func NewRecordingTemplateHandler(db *gorm.DB, namer *services.RecordingNamer) *RecordingTemplateHandler {
+	return &RecordingTemplateHandler{db: db, namer: namer}
+}
+
+type RecordingTemplateRequest struct {
+	Name      string `json:"name" binding:"required"`
+	Pattern   string `json:"pattern" binding:"required"`
+	IsDefault bool   `json:"is_default"`
+}
+
+type RecordingTemplateResponse struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Pattern   string `json:"pattern"`
+	IsDefault bool   `json:"is_default"`
+	Example   string `json:"example"`
+}
+
+func (h *RecordingTemplateHandler) toResponse(t *models.RecordingTemplate) RecordingTemplateResponse {
+	return RecordingTemplateResponse{
+		ID:        t.ID,
+		Name:      t.Name,
+		Pattern:   t.Pattern,
+		IsDefault: t.IsDefault,
+		Example:   services.SanitizeFilename(h.namer.Expand(t.Pattern, services.NameRequest{Event: "evento", Username: "utente"})),
+	}
+}
+
+func (h *RecordingTemplateHandler) ListTemplates(c *gin.Context) {
+	var templates []models.RecordingTemplate
+	if err := h.db.Order("name").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch templates",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := make([]RecordingTemplateResponse, 0, len(templates))
+	for i := range templates {
+		response = append(response, h.toResponse(&templates[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *RecordingTemplateHandler) CreateTemplate(c *gin.Context) {
+	var req RecordingTemplateRequest
+	if !h.bindTemplate(c, &req) {
+		return
+	}
+
+	tmpl := models.RecordingTemplate{
+		Name:      req.Name,
+		Pattern:   req.Pattern,
+		IsDefault: req.IsDefault,
+	}
+	if err := h.save(&tmpl); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to create template (name might be taken)",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, h.toResponse(&tmpl))
+}
+
+func (h *RecordingTemplateHandler) UpdateTemplate(c *gin.Context) {
+	var tmpl models.RecordingTemplate
+	if err := h.db.First(&tmpl, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Template not found",
+			ErrorCode: "NOT_FOUND",
+		})
+		return
+	}
+
+	var req RecordingTemplateRequest
+	if !h.bindTemplate(c, &req) {
+		return
+	}
+
+	tmpl.Name = req.Name
+	tmpl.Pattern = req.Pattern
+	tmpl.IsDefault = req.IsDefault
+	if err := h.save(&tmpl); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to update template (name might be taken)",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.toResponse(&tmpl))
+}
+
+func (h *RecordingTemplateHandler) DeleteTemplate(c *gin.Context) {
+	if err := h.db.Delete(&models.RecordingTemplate{}, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to delete template",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
+}
+
+// PreviewTemplate shows the filename a pattern or stored template would
+// produce right now, including collision suffixes.
+func (h *RecordingTemplateHandler) PreviewTemplate(c *gin.Context) {
+	var req struct {
+		Pattern  string `json:"pattern"`
+		Template string `json:"template"`
+		Event    string `json:"event"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	filename, err := h.namer.Resolve(services.NameRequest{
+		Filename: req.Pattern,
+		Template: req.Template,
+		Event:    req.Event,
+		Username: c.GetString("username"),
+		Now:      time.Now(),
+	})
+	if errors.Is(err, services.ErrTemplateNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "NOT_FOUND",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"filename": filename})
+}
+
+func (h *RecordingTemplateHandler) bindTemplate(c *gin.Context, req *RecordingTemplateRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return false
+	}
+
+	if services.SanitizeFilename(h.namer.Expand(req.Pattern, services.NameRequest{})) == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     "Pattern does not produce a valid filename",
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return false
+	}
+	return true
+}
+
+// save stores the template, keeping at most one default.
+func (h *RecordingTemplateHandler) save(tmpl *models.RecordingTemplate) error {
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		if tmpl.IsDefault {
+			if err := tx.Model(&models.RecordingTemplate{}).Where("id <> ?", tmpl.ID).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(tmpl).Error
+	})
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ReportHandler struct {
+	db *gorm.DB
+}
+
+func NewReportHandler(db *gorm.DB) *ReportHandler {
+	return &ReportHandler{db: db}
+}
+
+type PlayLogResponse struct {
+	ID            uint      `json:"id"`
+	SongTitle     string    `json:"song_title"`
+	StartedAt     time.Time `json:"started_at"`
+	EndedAt       time.Time `json:"ended_at"`
+	SecondsPlayed int       `json:"seconds_played"`
+	TrackLength   int       `json:"track_length"`
+	ServiceName   string    `json:"service_name,omitempty"`
+}
+
+// reportPeriod reads from/to (default: the current month). A plain date in
+// "to" includes that whole day.
+func reportPeriod(c *gin.Context) (time.Time, time.Time, bool) {
+	from, okFrom := parseTimeQuery(c, "from")
+	to, okTo := parseTimeQuery(c, "to")
+	if !okFrom || !okTo {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     "Invalid date (use RFC3339 or YYYY-MM-DD)",
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return time.Time{}, time.Time{}, false
+	}
+
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	if from != nil {
+		start = *from
+	}
+	end := now
+	if to != nil {
+		end = *to
+		if len(c.Query("to")) == len("2006-01-02") {
+			end = end.AddDate(0, 0, 1)
+		}
+	}
+	return start, end, true
+}
+
+// GetMusicUsage returns the songs played in the period grouped by song,
+// as JSON (default), CSV (?format=csv) or plain text (?format=txt).
+func (h *ReportHandler) GetMusicUsage(c *gin.Context) {
+	from, to, ok := reportPeriod(c)
+	if !ok {
+		return
+	}
+
+	report, err := services.BuildUsageReport(h.db, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to build report",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("music-usage_%s_%s", from.Format("2006-01-02"), to.Add(-time.Second).Format("2006-01-02"))
+
+	switch c.DefaultQuery("format", "json") {
+	case "csv":
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		report.WriteCSV(c.Writer)
+	case "txt":
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.txt"`)
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		report.WriteText(c.Writer)
+	case "json":
+		c.JSON(http.StatusOK, report)
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     "Unknown format (use json, csv or txt)",
+			ErrorCode: "INVALID_REQUEST",
+		})
+	}
+}
+
+// ListPlays returns the raw play log of the period, newest first.
+func (h *ReportHandler) ListPlays(c *gin.Context) {
+	from, to, ok := reportPeriod(c)
+	if !ok {
+		return
+	}
+	page, pageSize := parsePagination(c)
+
+	query := h.db.Model(&models.PlayLog{}).Where("started_at >= ? AND started_at < ?", from, to)
+	if q := c.Query("q"); q != "" {
+		query = query.Where("song_title LIKE ?", "%"+q+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var plays []models.PlayLog
+	if err := query.Order("started_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&plays).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch play log",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := make([]PlayLogResponse, 0, len(plays))
+	for _, p := range plays {
+		response = append(response, PlayLogResponse{
+			ID:            p.ID,
+			SongTitle:     p.SongTitle,
+			StartedAt:     p.StartedAt,
+			EndedAt:       p.EndedAt,
+			SecondsPlayed: p.SecondsPlayed,
+			TrackLength:   p.TrackLength,
+			ServiceName:   p.ServiceName,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"plays":     response,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
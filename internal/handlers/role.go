@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleHandler manages the role -> permissions bundles.
+type RoleHandler struct {
+	roles *services.RoleStore
+}
+
+func NewRoleHandler(roles *services.RoleStore) *RoleHandler {
+	return &RoleHandler{roles: roles}
+}
+
+type RoleRequest struct {
+	Description      string   `json:"description"`
+	Permissions      []string `json:"permissions"`
+	MaxSessions      int      `json:"max_sessions"`
+	SessionLimitMode string   `json:"session_limit_mode"`
+}
+
+type RoleResponse struct {
+	Name             string    `json:"name"`
+	Description      string    `json:"description"`
+	Permissions      []string  `json:"permissions"`
+	MaxSessions      int       `json:"max_sessions"`
+	SessionLimitMode string    `json:"session_limit_mode"`
+	BuiltIn          bool      `json:"built_in"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+func (h *RoleHandler) toRoleResponse(r *models.Role) RoleResponse {
+	return RoleResponse{
+		Name:             r.Name,
+		Description:      r.Description,
+		Permissions:      h.roles.Permissions(r.Name),
+		MaxSessions:      r.MaxSessions,
+		SessionLimitMode: r.SessionLimitMode,
+		BuiltIn:          r.BuiltIn,
+		UpdatedAt:        r.UpdatedAt,
+	}
+}
+
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.roles.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch roles",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := make([]RoleResponse, 0, len(roles))
+	for i := range roles {
+		response = append(response, h.toRoleResponse(&roles[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ListPermissions returns the permission catalog.
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, models.AllPermissions)
+}
+
+// SaveRole creates the role or replaces its description, permissions and
+// session limit.
+func (h *RoleHandler) SaveRole(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	role := models.Role{
+		Name:             c.Param("name"),
+		Description:      req.Description,
+		Permissions:      services.JoinTags(req.Permissions),
+		MaxSessions:      req.MaxSessions,
+		SessionLimitMode: req.SessionLimitMode,
+	}
+	if err := h.roles.Save(&role); err != nil {
+		status, code := http.StatusInternalServerError, "DATABASE_ERROR"
+		if errors.Is(err, services.ErrInvalidRole) {
+			status, code = http.StatusBadRequest, "INVALID_ROLE"
+		}
+		c.JSON(status, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: code,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.toRoleResponse(&role))
+}
+
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	err := h.roles.Delete(c.Param("name"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
+	case errors.Is(err, services.ErrRoleNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "NOT_FOUND",
+		})
+	case errors.Is(err, services.ErrRoleBuiltIn), errors.Is(err, services.ErrRoleInUse):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "ROLE_LOCKED",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to delete role",
+			ErrorCode: "DATABASE_ERROR",
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RuleHandler manages the automation rules.
+type RuleHandler struct {
+	db     *gorm.DB
+	engine *services.RuleEngine
+}
+
+func NewRuleHandler(db *gorm.DB, engine *services.RuleEngine) *RuleHandler {
+	return &RuleHandler{db: db, engine: engine}
+}
+
+type RuleRequest struct {
+	Name           string            `json:"name" binding:"required"`
+	Description    string            `json:"description"`
+	TriggerType    string            `json:"trigger_type" binding:"required"`
+	TriggerField   string            `json:"trigger_field"`
+	TriggerFrom    string            `json:"trigger_from"`
+	TriggerTo      string            `json:"trigger_to"`
+	TriggerCommand string            `json:"trigger_command"`
+	TriggerAt      string            `json:"trigger_at"`
+	Weekdays       []string          `json:"weekdays"`
+	After          string            `json:"after"`
+	Before         string            `json:"before"`
+	RequireState   map[string]string `json:"require_state"`
+	Actions        []services.Action `json:"actions" binding:"required"`
+	CooldownSec    int               `json:"cooldown_seconds" binding:"min=0"`
+	DryRun         bool              `json:"dry_run"`
+	Enabled        *bool             `json:"enabled"`
+}
+
+type RuleResponse struct {
+	ID             uint              `json:"id"`
+	Name           string            `json:"name"`
+	Description    string            `json:"description"`
+	TriggerType    string            `json:"trigger_type"`
+	TriggerField   string            `json:"trigger_field,omitempty"`
+	TriggerFrom    string            `json:"trigger_from,omitempty"`
+	TriggerTo      string            `json:"trigger_to,omitempty"`
+	TriggerCommand string            `json:"trigger_command,omitempty"`
+	TriggerAt      string            `json:"trigger_at,omitempty"`
+	Weekdays       []string          `json:"weekdays"`
+	After          string            `json:"after,omitempty"`
+	Before         string            `json:"before,omitempty"`
+	RequireState   map[string]string `json:"require_state,omitempty"`
+	Actions        []services.Action `json:"actions"`
+	CooldownSec    int               `json:"cooldown_seconds"`
+	DryRun         bool              `json:"dry_run"`
+	Enabled        bool              `json:"enabled"`
+	LastFiredAt    *time.Time        `json:"last_fired_at,omitempty"`
+}
+
+// RuleTestRequest simulates an event. Rule is only read by TestNewRule.
+type RuleTestRequest struct {
+	Rule  *RuleRequest       `json:"rule"`
+	Event services.RuleEvent `json:"event" binding:"required"`
+}
+
+func toRuleResponse(r *models.AutomationRule) RuleResponse {
+	actions, _ := services.DecodeActions(r.Actions)
+	var require map[string]string
+	json.Unmarshal([]byte(r.RequireState), &require)
+	return RuleResponse{
+		ID:             r.ID,
+		Name:           r.Name,
+		Description:    r.Description,
+		TriggerType:    r.TriggerType,
+		TriggerField:   r.TriggerField,
+		TriggerFrom:    r.TriggerFrom,
+		TriggerTo:      r.TriggerTo,
+		TriggerCommand: r.TriggerCommand,
+		TriggerAt:      r.TriggerAt,
+		Weekdays:       services.SplitTags(r.Weekdays),
+		After:          r.After,
+		Before:         r.Before,
+		RequireState:   require,
+		Actions:        actions,
+		CooldownSec:    r.CooldownSeconds,
+		DryRun:         r.DryRun,
+		Enabled:        r.Enabled,
+		LastFiredAt:    r.LastFiredAt,
+	}
+}
+
+func applyRuleRequest(r *models.AutomationRule, req *RuleRequest) {
+	r.Name = req.Name
+	r.Description = req.Description
+	r.TriggerType = req.TriggerType
+	r.TriggerField = req.TriggerField
+	r.TriggerFrom = req.TriggerFrom
+	r.TriggerTo = req.TriggerTo
+	r.TriggerCommand = req.TriggerCommand
+	r.TriggerAt = req.TriggerAt
+	r.Weekdays = services.JoinTags(req.Weekdays)
+	r.After = req.After
+	r.Before = req.Before
+	r.RequireState = ""
+	if len(req.RequireState) > 0 {
+		data, _ := json.Marshal(req.RequireState)
+		r.RequireState = string(data)
+	}
+	r.Actions = services.EncodeActions(req.Actions)
+	r.CooldownSeconds = req.CooldownSec
+	r.DryRun = req.DryRun
+	r.Enabled = req.Enabled == nil || *req.Enabled
+}
+
+func (h *RuleHandler) ListRules(c *gin.Context) {
+	var rules []models.AutomationRule
+	if err := h.db.Order("name").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch rules",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := make([]RuleResponse, 0, len(rules))
+	for i := range rules {
+		response = append(response, toRuleResponse(&rules[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetHistory returns the recent firings, including dry-run ones.
+func (h *RuleHandler) GetHistory(c *gin.Context) {
+	c.JSON(http.StatusOK, h.engine.History())
+}
+
+func (h *RuleHandler) CreateRule(c *gin.Context) {
+	var rule models.AutomationRule
+	if !h.bindRule(c, &rule) {
+		return
+	}
+
+	if err := h.db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to create rule (name might be taken)",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.engine.Reload()
+	c.JSON(http.StatusCreated, toRuleResponse(&rule))
+}
+
+func (h *RuleHandler) UpdateRule(c *gin.Context) {
+	var rule models.AutomationRule
+	if !h.findRule(c, &rule) {
+		return
+	}
+	if !h.bindRule(c, &rule) {
+		return
+	}
+
+	if err := h.db.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to update rule (name might be taken)",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.engine.Reload()
+	c.JSON(http.StatusOK, toRuleResponse(&rule))
+}
+
+func (h *RuleHandler) DeleteRule(c *gin.Context) {
+	if err := h.db.Unscoped().Delete(&models.AutomationRule{}, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to delete rule",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.engine.Reload()
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
+}
+
+// TestRule evaluates a saved rule against a simulated event without
+// running its actions.
+func (h *RuleHandler) TestRule(c *gin.Context) {
+	var rule models.AutomationRule
+	if !h.findRule(c, &rule) {
+		return
+	}
+
+	var req RuleTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	h.evaluate(c, &rule, req.Event)
+}
+
+// TestNewRule evaluates an unsaved rule, so it can be tried before saving.
+func (h *RuleHandler) TestNewRule(c *gin.Context) {
+	var req RuleTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Rule == nil {
+		msg := "rule is required"
+		if err != nil {
+			msg = err.Error()
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     msg,
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	var rule models.AutomationRule
+	applyRuleRequest(&rule, req.Rule)
+	h.evaluate(c, &rule, req.Event)
+}
+
+func (h *RuleHandler) evaluate(c *gin.Context, rule *models.AutomationRule, event services.RuleEvent) {
+	result, err := h.engine.Evaluate(rule, event)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_RULE",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *RuleHandler) findRule(c *gin.Context, rule *models.AutomationRule) bool {
+	if err := h.db.First(rule, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Rule not found",
+			ErrorCode: "NOT_FOUND",
+		})
+		return false
+	}
+	return true
+}
+
+func (h *RuleHandler) bindRule(c *gin.Context, rule *models.AutomationRule) bool {
+	var req RuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return false
+	}
+
+	applyRuleRequest(rule, &req)
+	if err := h.engine.Validate(rule); err != nil {
+		status, code := http.StatusInternalServerError, "INTERNAL_ERROR"
+		if errors.Is(err, services.ErrInvalidRule) {
+			status, code = http.StatusBadRequest, "INVALID_RULE"
+		}
+		c.JSON(status, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: code,
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ScheduleHandler struct {
+	db       *gorm.DB
+	schedule *services.Schedule
+}
+
+func NewScheduleHandler(db *gorm.DB, schedule *services.Schedule) *ScheduleHandler {
+	return &ScheduleHandler{db: db, schedule: schedule}
+}
+
+type ScheduledServiceRequest struct {
+	Name            string    `json:"name" binding:"required"`
+	StartsAt        time.Time `json:"starts_at" binding:"required"`
+	DurationMinutes int       `json:"duration_minutes" binding:"min=0"`
+	Weekly          bool      `json:"weekly"`
+	PresetID        string    `json:"preset_id"`
+	Notes           string    `json:"notes"`
+}
+
+type ScheduledServiceResponse struct {
+	ID              uint      `json:"id"`
+	Name            string    `json:"name"`
+	StartsAt        time.Time `json:"starts_at"`
+	DurationMinutes int       `json:"duration_minutes"`
+	Weekly          bool      `json:"weekly"`
+	PresetID        string    `json:"preset_id"`
+	Notes           string    `json:"notes"`
+}
+
+func toScheduledServiceResponse(svc *models.ScheduledService) ScheduledServiceResponse {
+	return ScheduledServiceResponse{
+		ID:              svc.ID,
+		Name:            svc.Name,
+		StartsAt:        svc.StartsAt,
+		DurationMinutes: svc.DurationMinutes,
+		Weekly:          svc.Weekly,
+		PresetID:        svc.PresetID,
+		Notes:           svc.Notes,
+	}
+}
+
+// GetSchedule lists the occurrences between from and to (default: the
+// next seven days), with weekly services expanded.
+func (h *ScheduleHandler) GetSchedule(c *gin.Context) {
+	from, okFrom := parseTimeQuery(c, "from")
+	to, okTo := parseTimeQuery(c, "to")
+	if !okFrom || !okTo {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     "Invalid date (use RFC3339 or YYYY-MM-DD)",
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	start := time.Now()
+	if from != nil {
+		start = *from
+	}
+	end := start.AddDate(0, 0, 7)
+	if to != nil {
+		end = *to
+	}
+
+	occurrences, err := h.schedule.Occurrences(start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch schedule",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+	if occurrences == nil {
+		occurrences = []services.ServiceOccurrence{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"occurrences": occurrences})
+}
+
+func (h *ScheduleHandler) ListServices(c *gin.Context) {
+	var list []models.ScheduledService
+	if err := h.db.Order("starts_at").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch services",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := make([]ScheduledServiceResponse, 0, len(list))
+	for i := range list {
+		response = append(response, toScheduledServiceResponse(&list[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *ScheduleHandler) CreateService(c *gin.Context) {
+	var req ScheduledServiceRequest
+	if !bindScheduledService(c, &req) {
+		return
+	}
+
+	svc := models.ScheduledService{}
+	applyScheduledService(&svc, &req)
+	if err := h.db.Create(&svc).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to create service",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, toScheduledServiceResponse(&svc))
+}
+
+func (h *ScheduleHandler) UpdateService(c *gin.Context) {
+	var svc models.ScheduledService
+	if err := h.db.First(&svc, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Service not found",
+			ErrorCode: "NOT_FOUND",
+		})
+		return
+	}
+
+	var req ScheduledServiceRequest
+	if !bindScheduledService(c, &req) {
+		return
+	}
+
+	applyScheduledService(&svc, &req)
+	if err := h.db.Save(&svc).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to update service",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, toScheduledServiceResponse(&svc))
+}
+
+func (h *ScheduleHandler) DeleteService(c *gin.Context) {
+	if err := h.db.Delete(&models.ScheduledService{}, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to delete service",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
+}
+
+func bindScheduledService(c *gin.Context, req *ScheduledServiceRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return false
+	}
+	return true
+}
+
+func applyScheduledService(svc *models.ScheduledService, req *ScheduledServiceRequest) {
+	svc.Name = req.Name
+	svc.StartsAt = req.StartsAt
+	svc.DurationMinutes = req.DurationMinutes
+	svc.Weekly = req.Weekly
+	svc.PresetID = req.PresetID
+	svc.Notes = req.Notes
+}
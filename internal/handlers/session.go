@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SessionHandler lists and revokes login sessions: users see their own,
+// admins see everyone's.
+type SessionHandler struct {
+	db       *gorm.DB
+	sessions *services.SessionManager
+}
+
+func NewSessionHandler(db *gorm.DB, sessions *services.SessionManager) *SessionHandler {
+	return &SessionHandler{db: db, sessions: sessions}
+}
+
+type SessionResponse struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id"`
+	Username     string    `json:"username,omitempty"`
+	DeviceInfo   string    `json:"device_info"`
+	IPAddress    string    `json:"ip_address"`
+	AccessMethod string    `json:"access_method"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActivity time.Time `json:"last_activity"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Current      bool      `json:"current"`
+}
+
+func toSessionResponse(s *models.Session, currentID string) SessionResponse {
+	return SessionResponse{
+		ID:           s.ID,
+		UserID:       s.UserID,
+		DeviceInfo:   s.DeviceInfo,
+		IPAddress:    s.IPAddress,
+		AccessMethod: s.AccessMethod,
+		CreatedAt:    s.CreatedAt,
+		LastActivity: s.LastActivity,
+		ExpiresAt:    s.ExpiresAt,
+		Current:      s.ID == currentID,
+	}
+}
+
+// ListOwnSessions returns the caller's active sessions.
+func (h *SessionHandler) ListOwnSessions(c *gin.Context) {
+	h.list(c, c.GetString("user_id"))
+}
+
+// RevokeOwnSession ends one of the caller's sessions.
+func (h *SessionHandler) RevokeOwnSession(c *gin.Context) {
+	h.revoke(c, c.GetString("user_id"))
+}
+
+// ListSessions returns every active session, optionally for one user.
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	h.list(c, c.Query("user_id"))
+}
+
+// RevokeSession ends any session.
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	h.revoke(c, "")
+}
+
+// RevokeUserSessions logs a user out of every device.
+func (h *SessionHandler) RevokeUserSessions(c *gin.Context) {
+	revoked, err := h.sessions.RevokeUser(c.Param("user_id"), "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to revoke sessions",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "revoked": revoked})
+}
+
+func (h *SessionHandler) list(c *gin.Context, userID string) {
+	sessions, err := h.sessions.Active(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch sessions",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	usernames := map[string]string{}
+	var users []models.User
+	h.db.Select("id", "username").Find(&users)
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+
+	current := c.GetString("session_id")
+	response := make([]SessionResponse, 0, len(sessions))
+	for i := range sessions {
+		s := toSessionResponse(&sessions[i], current)
+		s.Username = usernames[s.UserID]
+		response = append(response, s)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *SessionHandler) revoke(c *gin.Context, userID string) {
+	err := h.sessions.Revoke(c.Param("id"), userID)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
+	case errors.Is(err, services.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "Session not found",
+			ErrorCode: "NOT_FOUND",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to revoke session",
+			ErrorCode: "DATABASE_ERROR",
+		})
+	}
+}
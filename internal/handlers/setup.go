@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"crypto/subtle"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SetupHandler creates the first admin with a one-time setup token, when
+// the server starts with ADMIN_SETUP_TOKEN instead of seeding admin/admin123.
+type SetupHandler struct {
+	db        *gorm.DB
+	audit     *services.AuditService
+	passwords *services.Passwords
+
+	mu    sync.Mutex
+	token string // cleared once used
+}
+
+func NewSetupHandler(db *gorm.DB, audit *services.AuditService, passwords *services.Passwords, token string) *SetupHandler {
+	return &SetupHandler{db: db, audit: audit, passwords: passwords, token: token}
+}
+
+type SetupRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	FullName string `json:"full_name" binding:"required"`
+	Email    string `json:"email"`
+}
+
+// GetSetup tells the login page whether the first admin must be created.
+func (h *SetupHandler) GetSetup(c *gin.Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c.JSON(http.StatusOK, gin.H{"required": h.required()})
+}
+
+// Setup creates the first admin. It works once, and only while no admin
+// exists.
+func (h *SetupHandler) Setup(c *gin.Context) {
+	var req SetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.required() {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Success:   false,
+			Error:     "Setup already completed",
+			ErrorCode: "SETUP_DONE",
+		})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(h.token)) != 1 {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Success:   false,
+			Error:     "Invalid setup token",
+			ErrorCode: "INVALID_SETUP_TOKEN",
+		})
+		return
+	}
+
+	if !checkPassword(c, h.passwords, req.Password, req.Username) {
+		return
+	}
+	hashedPassword, err := h.passwords.Hash(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to hash password",
+			ErrorCode: "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	user := models.User{
+		ID:           uuid.New().String(),
+		Username:     req.Username,
+		PasswordHash: hashedPassword,
+		Role:         services.AdminRole,
+		FullName:     req.FullName,
+		Email:        req.Email,
+		IsActive:     true,
+		CreatedBy:    "setup",
+	}
+	if err := h.db.Create(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to create user",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+	h.token = ""
+
+	h.audit.LogUserChange(services.UserChangeEntry{
+		Action:          services.UserActionCreate,
+		TargetUserID:    user.ID,
+		TargetUsername:  user.Username,
+		PerformedByName: "setup",
+		ClientIP:        c.ClientIP(),
+		Changes:         services.UserChanges(nil, &user),
+	})
+	c.JSON(http.StatusCreated, toUserResponse(&user))
+}
+
+// required reports whether the setup can still run. Callers hold mu.
+func (h *SetupHandler) required() bool {
+	if h.token == "" {
+		return false
+	}
+	var admins int64
+	h.db.Model(&models.User{}).Where("role = ?", services.AdminRole).Count(&admins)
+	return admins == 0
+}
@@ -2,38 +2,81 @@ package handlers
 
 import (
 	"av-control/internal/models"
+	"av-control/internal/services"
+	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type UserHandler struct {
-	db *gorm.DB
+	db        *gorm.DB
+	roles     *services.RoleStore
+	audit     *services.AuditService
+	guard     *services.LoginGuard
+	passwords *services.Passwords
+	sessions  *services.SessionManager
 }
 
-func NewUserHandler(db *gorm.DB) *UserHandler {
-	return &UserHandler{db: db}
+func NewUserHandler(db *gorm.DB, roles *services.RoleStore, audit *services.AuditService, guard *services.LoginGuard, passwords *services.Passwords, sessions *services.SessionManager) *UserHandler {
+	return &UserHandler{db: db, roles: roles, audit: audit, guard: guard, passwords: passwords, sessions: sessions}
 }
 
 type CreateUserRequest struct {
 	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required,min=6"`
-	Role     string `json:"role" binding:"required,oneof=admin user"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role" binding:"required"` // name of an existing role
 	FullName string `json:"full_name" binding:"required"`
 	Email    string `json:"email"`
 }
 
 type UserResponse struct {
-	ID        string `json:"id"`
-	Username  string `json:"username"`
-	Role      string `json:"role"`
-	FullName  string `json:"full_name"`
-	Email     string `json:"email"`
-	IsActive  bool   `json:"is_active"`
-	CreatedAt string `json:"created_at"`
+	ID                 string     `json:"id"`
+	Username           string     `json:"username"`
+	Role               string     `json:"role"`
+	Permissions        []string   `json:"permissions,omitempty"` // only in /auth/me
+	FullName           string     `json:"full_name"`
+	Email              string     `json:"email"`
+	IsActive           bool       `json:"is_active"`
+	MustChangePassword bool       `json:"must_change_password"`
+	LastLogin          *time.Time `json:"last_login,omitempty"`
+	FailedLogins       int        `json:"failed_logins"`
+	LockedUntil        *time.Time `json:"locked_until,omitempty"`
+	CreatedAt          string     `json:"created_at"`
+}
+
+// UpdateUserRequest changes only the fields that are present.
+type UpdateUserRequest struct {
+	FullName *string `json:"full_name"`
+	Email    *string `json:"email"`
+	Role     *string `json:"role"`
+}
+
+type ResetPasswordRequest struct {
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+func toUserResponse(u *models.User) UserResponse {
+	var lockedUntil *time.Time
+	if u.LockedUntil != nil && u.LockedUntil.After(time.Now()) {
+		lockedUntil = u.LockedUntil
+	}
+	return UserResponse{
+		ID:                 u.ID,
+		Username:           u.Username,
+		Role:               u.Role,
+		FullName:           u.FullName,
+		Email:              u.Email,
+		IsActive:           u.IsActive,
+		MustChangePassword: u.MustChangePassword,
+		LastLogin:          u.LastLogin,
+		FailedLogins:       u.FailedLogins,
+		LockedUntil:        lockedUntil,
+		CreatedAt:          u.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
 }
 
 func (h *UserHandler) CreateUser(c *gin.Context) {
@@ -47,8 +90,30 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
+	if !h.roles.Exists(req.Role) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     "Unknown role " + req.Role,
+			ErrorCode: "INVALID_ROLE",
+		})
+		return
+	}
+
+	// Only admins can hand out the admin role
+	if req.Role == services.AdminRole && c.GetString("role") != services.AdminRole {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Success:   false,
+			Error:     "Only an admin can create admin users",
+			ErrorCode: "FORBIDDEN",
+		})
+		return
+	}
+
 	// hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if !checkPassword(c, h.passwords, req.Password, req.Username) {
+		return
+	}
+	hashedPassword, err := h.passwords.Hash(req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Success:   false,
@@ -64,7 +129,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	user := models.User{
 		ID:           uuid.New().String(),
 		Username:     req.Username,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 		Role:         req.Role,
 		FullName:     req.FullName,
 		Email:        req.Email,
@@ -81,15 +146,8 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Role:      user.Role,
-		FullName:  user.FullName,
-		Email:     user.Email,
-		IsActive:  user.IsActive,
-		CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-	})
+	h.logChange(c, services.UserActionCreate, &user, services.UserChanges(nil, &user))
+	c.JSON(http.StatusCreated, toUserResponse(&user))
 }
 
 func (h *UserHandler) ListUsers(c *gin.Context) {
@@ -104,16 +162,8 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 	}
 
 	var response []UserResponse
-	for _, u := range users {
-		response = append(response, UserResponse{
-			ID:        u.ID,
-			Username:  u.Username,
-			Role:      u.Role,
-			FullName:  u.FullName,
-			Email:     u.Email,
-			IsActive:  u.IsActive,
-			CreatedAt: u.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		})
+	for i := range users {
+		response = append(response, toUserResponse(&users[i]))
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -141,6 +191,27 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	// Only admins can remove admin users
+	var target models.User
+	if err := h.db.First(&target, "id = ?", id).Error; err == nil &&
+		target.Role == services.AdminRole && c.GetString("role") != services.AdminRole {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Success:   false,
+			Error:     "Only an admin can delete admin users",
+			ErrorCode: "FORBIDDEN",
+		})
+		return
+	}
+
+	if h.isLastAdmin(id) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Success:   false,
+			Error:     "Cannot delete the last active admin",
+			ErrorCode: "LAST_ADMIN",
+		})
+		return
+	}
+
 	if err := h.db.Delete(&models.User{}, "id = ?", id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Success:   false,
@@ -152,6 +223,448 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 
 	// Check if any rows were deleted
 	// (GORM Delete with soft delete will succeed even if ID doesn't exist, but it's fine for now)
+	if target.ID != "" {
+		h.logChange(c, services.UserActionDelete, &target, services.UserChanges(&target, nil))
+	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
 }
+
+// UpdateUser changes the name, email or role of a user.
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+	if !requireAdminFor(c, &user, "Only an admin can change admin users") {
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.FullName != nil {
+		if *req.FullName == "" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Success:   false,
+				Error:     "full_name cannot be empty",
+				ErrorCode: "INVALID_REQUEST",
+			})
+			return
+		}
+		updates["full_name"] = *req.FullName
+	}
+	if req.Email != nil {
+		updates["email"] = *req.Email
+	}
+	if req.Role != nil && *req.Role != user.Role {
+		if !h.roles.Exists(*req.Role) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Success:   false,
+				Error:     "Unknown role " + *req.Role,
+				ErrorCode: "INVALID_ROLE",
+			})
+			return
+		}
+		if *req.Role == services.AdminRole && c.GetString("role") != services.AdminRole {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Success:   false,
+				Error:     "Only an admin can grant the admin role",
+				ErrorCode: "FORBIDDEN",
+			})
+			return
+		}
+		if h.isLastAdmin(user.ID) {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Success:   false,
+				Error:     "Cannot demote the last active admin",
+				ErrorCode: "LAST_ADMIN",
+			})
+			return
+		}
+		updates["role"] = *req.Role
+	}
+
+	if len(updates) > 0 {
+		before := user
+		if err := h.db.Model(&user).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Success:   false,
+				Error:     "Failed to update user",
+				ErrorCode: "DATABASE_ERROR",
+			})
+			return
+		}
+
+		action := services.UserActionUpdate
+		if before.Role != user.Role {
+			action = services.UserActionRoleChange
+		}
+		h.logChange(c, action, &user, services.UserChanges(&before, &user))
+	}
+
+	c.JSON(http.StatusOK, toUserResponse(&user))
+}
+
+// ActivateUser lets a deactivated user log in again.
+func (h *UserHandler) ActivateUser(c *gin.Context) {
+	h.setActive(c, true)
+}
+
+// DeactivateUser blocks the login and ends every session, keeping the
+// account and its history.
+func (h *UserHandler) DeactivateUser(c *gin.Context) {
+	h.setActive(c, false)
+}
+
+func (h *UserHandler) setActive(c *gin.Context, active bool) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	if !requireAdminFor(c, &user, "Only an admin can activate or deactivate admin users") {
+		return
+	}
+	if !active {
+		if user.ID == c.GetString("user_id") {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Success:   false,
+				Error:     "Cannot deactivate your own account",
+				ErrorCode: "FORBIDDEN_ACTION",
+			})
+			return
+		}
+		if h.isLastAdmin(user.ID) {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Success:   false,
+				Error:     "Cannot deactivate the last active admin",
+				ErrorCode: "LAST_ADMIN",
+			})
+			return
+		}
+	}
+
+	before := user
+	if err := h.db.Model(&user).Update("is_active", active).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to update user",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+	var revokeErr error
+	if !active {
+		_, revokeErr = h.sessions.RevokeUser(user.ID, "")
+	}
+
+	action := services.UserActionActivate
+	if !active {
+		action = services.UserActionDeactivate
+	}
+	h.logChange(c, action, &user, services.UserChanges(&before, &user))
+
+	if revokeErr != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "User deactivated, but the sessions could not be ended",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, toUserResponse(&user))
+}
+
+// ResetPassword sets a temporary password the user must change at the next
+// login, and ends the user's sessions.
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	if !requireAdminFor(c, &user, "Only an admin can reset an admin password") {
+		return
+	}
+
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+
+	if !checkPassword(c, h.passwords, req.NewPassword, user.Username) {
+		return
+	}
+	hashedPassword, err := h.passwords.Hash(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to hash password",
+			ErrorCode: "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	before := user
+	now := time.Now()
+	err = h.db.Model(&user).Updates(map[string]interface{}{
+		"password_hash":        hashedPassword,
+		"must_change_password": true,
+		"password_changed_at":  &now,
+	}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to reset password",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+	_, revokeErr := h.sessions.RevokeUser(user.ID, "")
+
+	changes := services.UserChanges(&before, &user)
+	changes["password"] = services.FieldChange{After: "reset"}
+	h.logChange(c, services.UserActionPasswordReset, &user, changes)
+
+	if revokeErr != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Password reset, but the user's sessions could not be ended",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, toUserResponse(&user))
+}
+
+// UnlockUser lifts a login lockout and clears the failed attempts.
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	if !requireAdminFor(c, &user, "Only an admin can unlock admin users") {
+		return
+	}
+
+	before := toUserResponse(&user)
+	if err := h.guard.UnlockUser(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to unlock user",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.logChange(c, services.UserActionUnlock, &user, map[string]services.FieldChange{
+		"locked_until":  {Before: before.LockedUntil},
+		"failed_logins": {Before: before.FailedLogins, After: 0},
+	})
+	c.JSON(http.StatusOK, toUserResponse(&user))
+}
+
+// requireAdminFor answers 403 when a non-admin with users.manage acts on an
+// admin account.
+func requireAdminFor(c *gin.Context, user *models.User, message string) bool {
+	if user.Role == services.AdminRole && c.GetString("role") != services.AdminRole {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Success:   false,
+			Error:     message,
+			ErrorCode: "FORBIDDEN",
+		})
+		return false
+	}
+	return true
+}
+
+// ListLoginBlocks returns the IPs with recent failed logins and the locked
+// accounts.
+func (h *UserHandler) ListLoginBlocks(c *gin.Context) {
+	var users []models.User
+	h.db.Where("locked_until > ?", time.Now()).Find(&users)
+
+	locked := make([]UserResponse, 0, len(users))
+	for i := range users {
+		locked = append(locked, toUserResponse(&users[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"ips":   h.guard.Blocks(),
+		"users": locked,
+	})
+}
+
+// UnblockIP forgets the failed logins of an IP.
+func (h *UserHandler) UnblockIP(c *gin.Context) {
+	ip := c.Param("ip")
+	if !h.guard.UnlockIP(ip) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "No failed logins from this IP",
+			ErrorCode: "NOT_FOUND",
+		})
+		return
+	}
+
+	h.audit.LogUserChange(services.UserChangeEntry{
+		Action:          services.UserActionUnlock,
+		PerformedBy:     c.GetString("user_id"),
+		PerformedByName: c.GetString("username"),
+		ClientIP:        c.ClientIP(),
+		Changes:         map[string]services.FieldChange{"ip": {Before: ip}},
+	})
+	c.JSON(http.StatusOK, models.SuccessResponse{Success: true})
+}
+
+// checkPassword answers 400 WEAK_PASSWORD when password breaks the policy.
+func checkPassword(c *gin.Context, passwords *services.Passwords, password, username string) bool {
+	if err := passwords.Check(password, username); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "WEAK_PASSWORD",
+		})
+		return false
+	}
+	return true
+}
+
+// logChange records an account change made by the caller.
+func (h *UserHandler) logChange(c *gin.Context, action string, target *models.User, changes map[string]services.FieldChange) {
+	h.audit.LogUserChange(services.UserChangeEntry{
+		Action:          action,
+		TargetUserID:    target.ID,
+		TargetUsername:  target.Username,
+		PerformedBy:     c.GetString("user_id"),
+		PerformedByName: c.GetString("username"),
+		ClientIP:        c.ClientIP(),
+		Changes:         changes,
+	})
+}
+
+func (h *UserHandler) findUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+	if err := h.db.First(&user, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success:   false,
+			Error:     "User not found",
+			ErrorCode: "USER_NOT_FOUND",
+		})
+		return user, false
+	}
+	return user, true
+}
+
+// isLastAdmin reports whether userID is the only active admin left.
+func (h *UserHandler) isLastAdmin(userID string) bool {
+	var user models.User
+	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
+		return false
+	}
+	if user.Role != services.AdminRole || !user.IsActive {
+		return false
+	}
+
+	var others int64
+	h.db.Model(&models.User{}).
+		Where("role = ? AND is_active = ? AND id <> ?", services.AdminRole, true, userID).
+		Count(&others)
+	return others == 0
+}
+
+type UserAuditLogResponse struct {
+	ID              uint                            `json:"id"`
+	Action          string                          `json:"action"`
+	TargetUserID    string                          `json:"target_user_id"`
+	TargetUsername  string                          `json:"target_username"`
+	PerformedBy     string                          `json:"performed_by"`
+	PerformedByName string                          `json:"performed_by_name"`
+	ClientIP        string                          `json:"client_ip,omitempty"`
+	Changes         map[string]services.FieldChange `json:"changes"`
+	Timestamp       time.Time                       `json:"timestamp"`
+}
+
+// ListAuditLog browses the account change history, newest first. Filters:
+// target_user_id, performed_by, action, from, to (RFC3339 or YYYY-MM-DD).
+func (h *UserHandler) ListAuditLog(c *gin.Context) {
+	from, okFrom := parseTimeQuery(c, "from")
+	to, okTo := parseTimeQuery(c, "to")
+	if !okFrom || !okTo {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success:   false,
+			Error:     "Invalid date (use RFC3339 or YYYY-MM-DD)",
+			ErrorCode: "INVALID_REQUEST",
+		})
+		return
+	}
+	page, pageSize := parsePagination(c)
+
+	query := h.db.Model(&models.UserAuditLog{})
+	for _, field := range []string{"target_user_id", "performed_by", "action"} {
+		if value := c.Query(field); value != "" {
+			query = query.Where(field+" = ?", value)
+		}
+	}
+	if from != nil {
+		query = query.Where("timestamp >= ?", *from)
+	}
+	if to != nil {
+		end := *to
+		if len(c.Query("to")) == len("2006-01-02") {
+			end = end.AddDate(0, 0, 1)
+		}
+		query = query.Where("timestamp < ?", end)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var entries []models.UserAuditLog
+	if err := query.Order("timestamp DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success:   false,
+			Error:     "Failed to fetch audit log",
+			ErrorCode: "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := make([]UserAuditLogResponse, 0, len(entries))
+	for _, e := range entries {
+		changes := map[string]services.FieldChange{}
+		json.Unmarshal([]byte(e.Changes), &changes)
+		response = append(response, UserAuditLogResponse{
+			ID:              e.ID,
+			Action:          e.Action,
+			TargetUserID:    e.TargetUserID,
+			TargetUsername:  e.TargetUsername,
+			PerformedBy:     e.PerformedBy,
+			PerformedByName: e.PerformedByName,
+			ClientIP:        e.ClientIP,
+			Changes:         changes,
+			Timestamp:       e.Timestamp,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":   response,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
@@ -1,25 +1,25 @@
 package handlers
 
 import (
+	"av-control/internal/middleware"
 	"av-control/internal/services"
 	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/websocket"
 )
 
 type WebSocketHandler struct {
-	hub       *services.Hub
-	jwtSecret string
-	upgrader  websocket.Upgrader
+	hub      *services.Hub
+	auth     *services.Authenticator
+	upgrader websocket.Upgrader
 }
 
-func NewWebSocketHandler(hub *services.Hub, jwtSecret string) *WebSocketHandler {
+func NewWebSocketHandler(hub *services.Hub, auth *services.Authenticator) *WebSocketHandler {
 	return &WebSocketHandler{
-		hub:       hub,
-		jwtSecret: jwtSecret,
+		hub:  hub,
+		auth: auth,
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
@@ -39,29 +39,17 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
-	// 2. Validate JWT token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(h.jwtSecret), nil
-	})
-
-	if err != nil || !token.Valid {
-		c.JSON(401, gin.H{"error": "Invalid token"})
-		return
-	}
-
-	// 3. Extract user info from claims
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		c.JSON(401, gin.H{"error": "Invalid claims"})
+	// 2. Validate the token, its session and the user as the API does, so a
+	// logged out, revoked or deactivated session cannot subscribe
+	identity, err := h.auth.Authenticate(tokenString)
+	if err != nil {
+		c.JSON(middleware.AuthError(err))
 		return
 	}
 
-	userID, _ := claims["user_id"].(string)
-	username, _ := claims["username"].(string)
-	role, _ := claims["role"].(string)
-
-	if userID == "" || username == "" {
-		c.JSON(401, gin.H{"error": "Invalid user info"})
+	// 3. The events are not for users who still must change the password
+	if identity.MustChangePassword {
+		c.JSON(403, middleware.PasswordChangeRequired)
 		return
 	}
 
@@ -77,9 +65,9 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		Hub:      h.hub,
 		Conn:     conn,
 		Send:     make(chan []byte, 256),
-		UserID:   userID,
-		Username: username,
-		Role:     role,
+		UserID:   identity.UserID,
+		Username: identity.Username,
+		Role:     identity.Role, // the stored role, not the one in the token
 	}
 
 	// 6. Register client with hub
@@ -1,6 +1,13 @@
 package hardware
 
-import "av-control/internal/models"
+import (
+	"av-control/internal/models"
+	"errors"
+	"net/http"
+)
+
+// ErrNotSupported is returned when the backend does not implement a command.
+var ErrNotSupported = errors.New("not supported by the device")
 
 type HardwareClient interface {
 	// Presets
@@ -19,7 +26,10 @@ type HardwareClient interface {
 	Next() error
 	Previous() error
 	SetRepeatMode(mode string) error
+	Seek(position int) error
+	SetShuffle(enabled bool) error
 	GetPlayerStatus() (*models.PlayerStatus, error)
+	GetPlayerCapabilities() (*models.PlayerCapabilities, error)
 
 	// Recorder
 	StartRecording(filename string) (string, error)
@@ -34,3 +44,16 @@ type HardwareClient interface {
 	// System
 	GetSystemStatus() (*models.SystemStatus, error)
 }
+
+// RecordingFileSource is implemented by clients whose backend can stream
+// recorded files. rangeHeader is forwarded as-is so the backend can answer
+// partial requests.
+type RecordingFileSource interface {
+	OpenRecording(filename string, rangeHeader string) (*http.Response, error)
+}
+
+// MuteReader is implemented by clients that can read a control's mute state
+// separately from its volume (GetControlValue reports the volume first).
+type MuteReader interface {
+	GetControlMute(controlID string) (bool, error)
+}
@@ -4,9 +4,13 @@ import (
 	"av-control/internal/models"
 	"errors"
 	"fmt"
+	"math/rand"
 	"time"
 )
 
+// mockTrackLength is the duration in seconds of every mock song.
+const mockTrackLength = 300
+
 type MockHardwareClient struct {
 	// Presets
 	presets       []models.Preset
@@ -19,6 +23,7 @@ type MockHardwareClient struct {
 	currentSongID    int
 	playerState      string
 	repeatMode       string
+	shuffle          bool
 	currentSongTime  int
 	lastStatusUpdate time.Time
 
@@ -158,6 +163,18 @@ func (m *MockHardwareClient) Stop() error {
 }
 
 func (m *MockHardwareClient) Next() error {
+	if m.shuffle && len(m.songs) > 1 {
+		for {
+			next := m.songs[rand.Intn(len(m.songs))].ID
+			if next != m.currentSongID {
+				m.currentSongID = next
+				break
+			}
+		}
+		m.currentSongTime = 0
+		return nil
+	}
+
 	found := false
 	for i, s := range m.songs {
 		if s.ID == m.currentSongID {
@@ -196,6 +213,28 @@ func (m *MockHardwareClient) SetRepeatMode(mode string) error {
 	return nil
 }
 
+func (m *MockHardwareClient) Seek(position int) error {
+	if position < 0 || position > mockTrackLength {
+		return errors.New("position out of range")
+	}
+	m.currentSongTime = position
+	m.lastStatusUpdate = time.Now()
+	return nil
+}
+
+func (m *MockHardwareClient) SetShuffle(enabled bool) error {
+	m.shuffle = enabled
+	return nil
+}
+
+func (m *MockHardwareClient) GetPlayerCapabilities() (*models.PlayerCapabilities, error) {
+	return &models.PlayerCapabilities{
+		RepeatModes: []string{"none", "song", "group"},
+		Seek:        true,
+		Shuffle:     true,
+	}, nil
+}
+
 func (m *MockHardwareClient) GetPlayerStatus() (*models.PlayerStatus, error) {
 	if m.playerState == "playing" {
 		elapsed := int(time.Since(m.lastStatusUpdate).Seconds())
@@ -211,7 +250,7 @@ func (m *MockHardwareClient) GetPlayerStatus() (*models.PlayerStatus, error) {
 		}
 	}
 
-	totalTime := 300
+	totalTime := mockTrackLength
 
 	if m.currentSongTime > totalTime {
 		m.currentSongTime = totalTime
@@ -223,6 +262,7 @@ func (m *MockHardwareClient) GetPlayerStatus() (*models.PlayerStatus, error) {
 		CurrentTime: m.currentSongTime,
 		TotalTime:   totalTime,
 		RepeatMode:  m.repeatMode,
+		Shuffle:     m.shuffle,
 	}, nil
 }
 
@@ -282,6 +322,17 @@ func (m *MockHardwareClient) GetControlValue(controlID string) (*models.ControlV
 	return nil, errors.New("control not found")
 }
 
+func (m *MockHardwareClient) GetControlMute(controlID string) (bool, error) {
+	var id int
+	fmt.Sscanf(controlID, "%d", &id)
+
+	mute, ok := m.mutes[id]
+	if !ok {
+		return false, errors.New("control not found")
+	}
+	return mute, nil
+}
+
 func (m *MockHardwareClient) SetControlValue(controlID string, value interface{}) error {
 	var id int
 	fmt.Sscanf(controlID, "%d", &id)
@@ -4,12 +4,37 @@ import (
 	"av-control/internal/models"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 )
 
+// StatusError is returned when the daemon answers with a non-200 status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("hardware error (HTTP %d): %s", e.StatusCode, e.Body)
+}
+
+// notSupported maps "unknown endpoint" answers from the daemon to
+// ErrNotSupported.
+func notSupported(err error) error {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			return ErrNotSupported
+		}
+	}
+	return err
+}
+
 type RealHardwareClient struct {
 	baseURL string
 	client  *http.Client
@@ -34,7 +59,7 @@ func (r *RealHardwareClient) get(path string, result interface{}) error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("hardware error (HTTP %d): %s", resp.StatusCode, string(body))
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	if result != nil {
@@ -66,7 +91,7 @@ func (r *RealHardwareClient) post(path string, payload interface{}, result inter
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("hardware error (HTTP %d): %s", resp.StatusCode, string(bodyBytes))
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
 	}
 
 	if result != nil {
@@ -150,12 +175,39 @@ func (r *RealHardwareClient) SetRepeatMode(mode string) error {
 	return r.post("/api/device/player/repeat", payload, nil)
 }
 
+func (r *RealHardwareClient) Seek(position int) error {
+	payload := map[string]int{"position": position}
+	return notSupported(r.post("/api/device/player/seek", payload, nil))
+}
+
+func (r *RealHardwareClient) SetShuffle(enabled bool) error {
+	payload := map[string]bool{"enabled": enabled}
+	return notSupported(r.post("/api/device/player/shuffle", payload, nil))
+}
+
 func (r *RealHardwareClient) GetPlayerStatus() (*models.PlayerStatus, error) {
 	var response models.PlayerStatus
 	err := r.get("/api/device/player/status", &response)
 	return &response, err
 }
 
+// GetPlayerCapabilities asks the daemon what it supports; older daemons
+// without the endpoint get the repeat modes they are known to accept.
+func (r *RealHardwareClient) GetPlayerCapabilities() (*models.PlayerCapabilities, error) {
+	var response models.PlayerCapabilities
+	err := r.get("/api/device/player/capabilities", &response)
+	if notSupported(err) == ErrNotSupported {
+		return &models.PlayerCapabilities{RepeatModes: []string{"none", "song", "group"}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(response.RepeatModes) == 0 {
+		response.RepeatModes = []string{"none", "song", "group"}
+	}
+	return &response, nil
+}
+
 // ============================================================================
 // RECORDER
 // ============================================================================
@@ -189,6 +241,33 @@ func (r *RealHardwareClient) GetRecorderStatus() (*models.RecorderStatus, error)
 	return &response, err
 }
 
+// OpenRecording streams a recorded file from the daemon. The caller must
+// close the response body.
+func (r *RealHardwareClient) OpenRecording(filename string, rangeHeader string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, r.baseURL+"/api/device/recorder/files/"+url.PathEscape(filename), nil)
+	if err != nil {
+		return nil, err
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	// Downloads can take much longer than the regular command timeout
+	client := &http.Client{Transport: r.client.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP GET failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("hardware error (HTTP %d): %s", resp.StatusCode, string(body))
+	}
+
+	return resp, nil
+}
+
 // ============================================================================
 // CONTROLS
 // ============================================================================
@@ -231,6 +310,18 @@ func (r *RealHardwareClient) GetControlValue(controlID string) (*models.ControlV
 	}, nil
 }
 
+// GetControlMute reads only the mute state of a control.
+func (r *RealHardwareClient) GetControlMute(controlID string) (bool, error) {
+	var muteResp struct {
+		ID   int  `json:"id"`
+		Mute bool `json:"mute"`
+	}
+	if err := r.get("/api/device/controls/mute/"+controlID, &muteResp); err != nil {
+		return false, err
+	}
+	return muteResp.Mute, nil
+}
+
 func (r *RealHardwareClient) SetControlValue(controlID string, value interface{}) error {
 	payload := map[string]interface{}{"value": value}
 
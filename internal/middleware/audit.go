@@ -55,7 +55,7 @@ func AuditMiddleware(auditService *services.AuditService) gin.HandlerFunc {
 		}
 
 		// Detect remote vs local access
-		isRemote := isRemoteAccess(c.ClientIP())
+		isRemote := services.IsRemoteAccess(c.ClientIP())
 
 		// Queue audit log entry (async, non-blocking)
 		auditService.LogCommand(services.CommandLogEntry{
@@ -85,9 +85,13 @@ func shouldAudit(path string) bool {
 			path == "/api/device/player/next" ||
 			path == "/api/device/player/previous" ||
 			path == "/api/device/player/repeat" ||
+			path == "/api/device/player/seek" ||
+			path == "/api/device/player/shuffle" ||
 			path == "/api/device/recorder/start" ||
 			path == "/api/device/recorder/stop" ||
-			path == "/api/device/controls/:id")
+			path == "/api/device/controls/:id" ||
+			path == "/api/device/emergency/mute-all" ||
+			path == "/api/device/emergency/restore")
 }
 
 func getCommandType(c *gin.Context) string {
@@ -119,10 +123,18 @@ func getCommandType(c *gin.Context) string {
 		return "player.previous"
 	case "/api/device/player/repeat":
 		return "player.repeat"
+	case "/api/device/player/seek":
+		return "player.seek"
+	case "/api/device/player/shuffle":
+		return "player.shuffle"
 	case "/api/device/recorder/start":
 		return "recorder.start"
 	case "/api/device/recorder/stop":
 		return "recorder.stop"
+	case "/api/device/emergency/mute-all":
+		return "emergency.mute_all"
+	case "/api/device/emergency/restore":
+		return "emergency.restore"
 	case "/api/device/controls/:id":
 		controlID := c.Param("id")
 		if method == "POST" {
@@ -133,12 +145,3 @@ func getCommandType(c *gin.Context) string {
 		return "unknown"
 	}
 }
-
-func isRemoteAccess(clientIP string) bool {
-	// Simple local detection (localhost, 192.168.x.x, 10.x.x.x)
-	if clientIP == "127.0.0.1" || clientIP == "::1" || clientIP == "localhost" {
-		return false
-	}
-	// TODO: More sophisticated detection based on network configuration
-	return true
-}
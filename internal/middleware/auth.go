@@ -2,18 +2,16 @@ package middleware
 
 import (
 	"av-control/internal/models"
-	"crypto/sha256"
-	"encoding/hex"
-	"fmt"
+	"av-control/internal/services"
+	"errors"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"gorm.io/gorm"
 )
 
-func JWTAuthMiddleware(jwtSecret string, db *gorm.DB) gin.HandlerFunc {
+// JWTAuthMiddleware lets the request through with a valid access token of
+// a live session and an active user.
+func JWTAuthMiddleware(auth *services.Authenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 1. Extract Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -37,73 +35,50 @@ func JWTAuthMiddleware(jwtSecret string, db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		tokenString := parts[1]
-
-		// 3. Parse and validate JWT
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method")
-			}
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(401, models.ErrorResponse{
-				Success:   false,
-				Error:     "Invalid or expired token",
-				ErrorCode: "INVALID_TOKEN",
-			})
+		// 3. Validate the token, its session and the user
+		identity, err := auth.Authenticate(parts[1])
+		if err != nil {
+			status, body := AuthError(err)
+			c.AbortWithStatusJSON(status, body)
 			return
 		}
 
-		// 4. Extract claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(401, models.ErrorResponse{
-				Success:   false,
-				Error:     "Invalid token claims",
-				ErrorCode: "INVALID_TOKEN",
-			})
+		// 4. Until a required password change is done only the auth endpoints
+		// (me, password, logout, sessions) work
+		if identity.MustChangePassword && !strings.HasPrefix(c.FullPath(), "/api/auth/") {
+			c.AbortWithStatusJSON(403, PasswordChangeRequired)
 			return
 		}
 
-		userID, _ := claims["user_id"].(string)
-		username, _ := claims["username"].(string)
-		role, _ := claims["role"].(string)
-
-		// 5. Hash token and verify session exists in DB
-		tokenHash := sha256.Sum256([]byte(tokenString))
-		tokenHashStr := hex.EncodeToString(tokenHash[:])
-
-		var session models.Session
-		result := db.Where("token_hash = ? AND expires_at > ?", tokenHashStr, time.Now()).First(&session)
-		if result.Error != nil {
-			c.AbortWithStatusJSON(401, models.ErrorResponse{
-				Success:   false,
-				Error:     "Session not found or expired",
-				ErrorCode: "SESSION_EXPIRED",
-			})
-			return
-		}
+		// 5. Set user info in context
+		c.Set("user_id", identity.UserID)
+		c.Set("session_id", identity.SessionID)
+		c.Set("username", identity.Username)
+		c.Set("role", identity.Role)
 
-		// 6. Verify user still exists and is active
-		var user models.User
-		result = db.First(&user, "id = ? AND is_active = ?", userID, true)
-		if result.Error != nil {
-			c.AbortWithStatusJSON(401, models.ErrorResponse{
-				Success:   false,
-				Error:     "User not found or inactive",
-				ErrorCode: "USER_INACTIVE",
-			})
-			return
-		}
+		c.Next()
+	}
+}
 
-		// 7. Set user info in context
-		c.Set("user_id", userID)
-		c.Set("username", username)
-		c.Set("role", role)
+// PasswordChangeRequired is returned to a user who must change the password
+// before using anything but the auth endpoints.
+var PasswordChangeRequired = models.ErrorResponse{
+	Success:   false,
+	Error:     "Password change required",
+	ErrorCode: "PASSWORD_CHANGE_REQUIRED",
+}
 
-		c.Next()
+// AuthError maps an Authenticator error to its response.
+func AuthError(err error) (int, models.ErrorResponse) {
+	switch {
+	case errors.Is(err, services.ErrInvalidKeyToken):
+		return 401, models.ErrorResponse{Success: false, Error: "Invalid or expired token", ErrorCode: "INVALID_TOKEN"}
+	case errors.Is(err, services.ErrSessionNotFound):
+		return 401, models.ErrorResponse{Success: false, Error: "Session not found or expired", ErrorCode: "SESSION_EXPIRED"}
+	case errors.Is(err, services.ErrUserInactive):
+		return 401, models.ErrorResponse{Success: false, Error: "User not found or inactive", ErrorCode: "USER_INACTIVE"}
+	default:
+		return 500, models.ErrorResponse{Success: false, Error: "Failed to verify session", ErrorCode: "DATABASE_ERROR"}
 	}
 }
 
@@ -140,3 +115,30 @@ func RequireRole(roles ...string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequirePermission allows the request when the user's role grants
+// permission. Must run after JWTAuthMiddleware.
+func RequirePermission(roles *services.RoleStore, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRole := c.GetString("role")
+		if userRole == "" {
+			c.AbortWithStatusJSON(401, models.ErrorResponse{
+				Success:   false,
+				Error:     "Unauthorized",
+				ErrorCode: "UNAUTHORIZED",
+			})
+			return
+		}
+
+		if !roles.Has(userRole, permission) {
+			c.AbortWithStatusJSON(403, models.ErrorResponse{
+				Success:   false,
+				Error:     "Missing permission " + permission,
+				ErrorCode: "FORBIDDEN",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
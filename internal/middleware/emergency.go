@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"av-control/internal/models"
+	"av-control/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EmergencyLock rejects changes from non-admins while the emergency mute is
+// active. Reads stay allowed so clients can keep showing the state.
+func EmergencyLock(emergency *services.Emergency) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.GetString("role") == "admin" || !emergency.Active() {
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusLocked, models.ErrorResponse{
+			Success:   false,
+			Error:     "Emergency mute is active, only an admin can change the system",
+			ErrorCode: "EMERGENCY_LOCK",
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+import "gorm.io/gorm"
+
+// AccessRule grants or denies one control, preset or source to a role or
+// a single user. ResourceID "*" matches every resource of the type.
+type AccessRule struct {
+	gorm.Model
+	SubjectType  string `gorm:"index;not null"` // role, user
+	Subject      string `gorm:"index;not null"` // role name or user ID
+	ResourceType string `gorm:"index;not null"` // control, preset, source
+	ResourceID   string `gorm:"not null"`
+	Effect       string `gorm:"not null"` // allow, deny
+	Note         string
+}
@@ -21,9 +21,12 @@ type CommandLog struct {
 
 type UserAuditLog struct {
 	gorm.Model
-	Action       string `gorm:"not null"` // create, update, delete, password_reset
-	TargetUserID string `gorm:"index;not null"`
-	PerformedBy  string `gorm:"index;not null"`
-	Changes      string // JSON
-	Timestamp    time.Time
+	Action          string `gorm:"index;not null"` // see services.UserAction*
+	TargetUserID    string `gorm:"index;not null"`
+	TargetUsername  string // kept so the entry stays readable after a delete
+	PerformedBy     string `gorm:"index;not null"`
+	PerformedByName string
+	ClientIP        string
+	Changes         string    // JSON: field -> {before, after}
+	Timestamp       time.Time `gorm:"index"`
 }
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Snapshot stores control values captured from the mixer so they can be
+// applied again later (e.g. per-venue adjustments after a preset load).
+type Snapshot struct {
+	gorm.Model
+	Name      string `gorm:"uniqueIndex;not null"`
+	Controls  string `gorm:"type:text"` // JSON array of services.ControlState
+	CreatedBy string
+}
+
+// Macro is a named, reusable list of actions.
+type Macro struct {
+	gorm.Model
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string
+	Actions     string `gorm:"type:text"` // JSON array of services.Action
+}
+
+// PresetHook holds the actions run after a preset loads successfully.
+type PresetHook struct {
+	PresetID  string `gorm:"primaryKey"`
+	Actions   string `gorm:"type:text"` // JSON array of services.Action
+	Enabled   bool
+	UpdatedAt time.Time
+}
+
+// AutomationRule runs its actions when the trigger fires and every
+// condition holds. Trigger types: state (a status field changes), command
+// (a user command is executed) and time (a wall clock minute).
+type AutomationRule struct {
+	gorm.Model
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string
+
+	TriggerType    string // state, command, time
+	TriggerField   string // state: player.state, player.song, recorder.state, preset, connected
+	TriggerFrom    string // state: previous value (empty = any)
+	TriggerTo      string // state: new value (empty = any)
+	TriggerCommand string // command: e.g. "recorder.start", "controls.*"
+	TriggerAt      string // time: "HH:MM"
+
+	// Conditions
+	Weekdays     string // comma separated, 0 = Sunday (empty = every day)
+	After        string // "HH:MM", inclusive (empty = no lower bound)
+	Before       string // "HH:MM", exclusive (empty = no upper bound)
+	RequireState string `gorm:"type:text"` // JSON object field -> value
+
+	Actions         string `gorm:"type:text"` // JSON array of services.Action
+	CooldownSeconds int
+	DryRun          bool // log and record firings without running the actions
+	Enabled         bool
+	LastFiredAt     *time.Time
+}
@@ -0,0 +1,28 @@
+package models
+
+import "gorm.io/gorm"
+
+// BackgroundMusicWindow is a daily time range in which background music
+// plays automatically.
+type BackgroundMusicWindow struct {
+	gorm.Model
+	Name      string `gorm:"not null"`
+	Weekdays  string // comma separated, 0 = Sunday; empty = every day
+	StartTime string `gorm:"not null"` // "HH:MM"
+	EndTime   string `gorm:"not null"` // "HH:MM", before StartTime = ends the next day
+	SourceID  int
+	SongID    *int   // first song, optional
+	ControlID string // volume control used for the music
+	Volume    float64
+	Enabled   bool
+}
+
+// BackgroundMusicSettings is the single row with the global mode settings.
+type BackgroundMusicSettings struct {
+	ID                   uint `gorm:"primaryKey"`
+	Enabled              bool
+	InterruptMode        string  // "stop" or "duck" during services and after preset loads
+	DuckVolume           float64 // volume while ducked
+	PresetPauseMinutes   int     // how long a preset load interrupts the music
+	ServiceMarginMinutes int     // interrupt this many minutes before a scheduled service
+}
@@ -0,0 +1,16 @@
+package models
+
+import "gorm.io/gorm"
+
+// DuckingRule lowers the "music" controls while any of the "voice"
+// controls (microphones) is unmuted.
+type DuckingRule struct {
+	gorm.Model
+	Name          string  `gorm:"not null"`
+	VoiceControls string  // comma separated control IDs
+	MusicControls string  // comma separated control IDs
+	DuckBy        float64 // dB subtracted from the music controls
+	RampMs        int     // fade duration
+	HoldMs        int     // wait after the last voice mutes before restoring
+	Enabled       bool
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Presets
 type Preset struct {
 	ID   string `json:"id"`
@@ -40,6 +42,16 @@ type PlayerStatus struct {
 	CurrentTime int    `json:"current_time"`
 	TotalTime   int    `json:"total_time"`
 	RepeatMode  string `json:"repeat_mode"`
+	Shuffle     bool   `json:"shuffle"`
+}
+
+// PlayerCapabilities describes what the player backend supports. When the
+// daemon cannot seek, the server emulates a seek to the start by restarting
+// the current track; other positions are refused.
+type PlayerCapabilities struct {
+	RepeatModes []string `json:"repeat_modes"`
+	Seek        bool     `json:"seek"`
+	Shuffle     bool     `json:"shuffle"`
 }
 
 // Recorder
@@ -47,6 +59,9 @@ type RecorderStatus struct {
 	State       string `json:"state"`
 	Filename    string `json:"filename,omitempty"`
 	CurrentTime int    `json:"current_time,omitempty"`
+
+	// Set by the server when the recording has an auto-stop deadline
+	StopAt *time.Time `json:"stop_at,omitempty"`
 }
 
 // Controls
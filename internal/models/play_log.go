@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// PlayLog is one track that actually played, as observed from the player
+// status (not from SelectSong calls). Used for music licensing reports.
+type PlayLog struct {
+	ID            uint      `gorm:"primaryKey"`
+	SongTitle     string    `gorm:"index;not null"`
+	StartedAt     time.Time `gorm:"index"`
+	EndedAt       time.Time
+	SecondsPlayed int
+	TrackLength   int    // seconds, as reported by the player
+	ServiceName   string // scheduled service in progress, if any
+}
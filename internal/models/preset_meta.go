@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// PresetMeta is the server-side overlay for a daemon preset: the daemon only
+// knows ID and name, everything shown to operators lives here.
+type PresetMeta struct {
+	PresetID     string `gorm:"primaryKey"`
+	DisplayName  string
+	Description  string
+	Color        string // CSS color, e.g. "#2563eb"
+	Icon         string
+	SortOrder    int
+	VisibleRoles string // comma separated, empty = visible to every role
+	Favorite     bool
+	UpdatedAt    time.Time
+}
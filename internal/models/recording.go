@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Recording is one StartRecording/StopRecording pair
+type Recording struct {
+	gorm.Model
+	Filename        string    `gorm:"index;not null"`
+	StartedAt       time.Time `gorm:"index"`
+	StoppedAt       *time.Time
+	DurationSeconds int
+	StartedBy       string `gorm:"index"` // user ID, empty when started outside the server
+	StartedByName   string
+	StoppedBy       string
+	PresetID        string
+	StopReason      string // manual, max_duration, scheduled_end, player_idle, end_preset, external
+	Tags            string // comma separated
+	Notes           string
+	FilePurgedAt    *time.Time // set when retention deleted the file
+
+	// Archival to the backup target
+	ArchiveStatus   string `gorm:"index"` // "", pending, archiving, archived, failed
+	ArchiveTarget   string
+	ArchiveAttempts int
+	ArchiveChecksum string // SHA-256 of the archived copy
+	ArchiveError    string
+	ArchivedAt      *time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+import "gorm.io/gorm"
+
+// RecordingTemplate is an admin-defined filename pattern for new recordings.
+// Supported tokens: {date} {time} {datetime} {weekday} {preset} {event} {user}
+type RecordingTemplate struct {
+	gorm.Model
+	Name      string `gorm:"uniqueIndex;not null"`
+	Pattern   string `gorm:"not null"`
+	IsDefault bool
+}
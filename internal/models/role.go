@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// Permissions checked by middleware.RequirePermission. The admin role
+// always holds every permission.
+const (
+	PermPlayerControl  = "player.control"
+	PermControlsSet    = "controls.set"
+	PermPresetsLoad    = "presets.load"
+	PermRecorderManage = "recorder.manage"
+	PermAutomationRun  = "automation.run"
+	PermUsersManage    = "users.manage"
+)
+
+// AllPermissions lists every permission with a short description.
+var AllPermissions = []PermissionInfo{
+	{PermPlayerControl, "Select sources and songs, play, pause, stop and seek"},
+	{PermControlsSet, "Change mixer volumes and mutes"},
+	{PermPresetsLoad, "Load presets"},
+	{PermRecorderManage, "Start and stop recordings"},
+	{PermAutomationRun, "Apply snapshots and run macros"},
+	{PermUsersManage, "Create and delete users"},
+}
+
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Role is a named bundle of permissions assigned to users through
+// User.Role. Built-in roles are seeded at startup and cannot be deleted.
+type Role struct {
+	Name        string `gorm:"primaryKey"`
+	Description string
+	Permissions string // comma separated
+	BuiltIn     bool
+	UpdatedAt   time.Time
+
+	// Concurrent sessions per user: 0 uses the server default
+	// (SESSION_MAX_PER_USER). When the limit is reached a login either ends
+	// the least recently used session (evict_oldest) or is refused (reject).
+	MaxSessions      int
+	SessionLimitMode string
+}
+
+// DefaultRoles are seeded when missing. "user" keeps what non-admin users
+// could do before permissions existed.
+var DefaultRoles = []Role{
+	{Name: "admin", Description: "Full access", BuiltIn: true},
+	{Name: "user", Description: "Operator (legacy role)", BuiltIn: true,
+		Permissions: "player.control,controls.set,presets.load,recorder.manage,automation.run"},
+	{Name: "priest", Description: "Celebrant: presets, player and recordings", BuiltIn: true,
+		Permissions: "player.control,presets.load,recorder.manage"},
+	{Name: "technician", Description: "Sound technician", BuiltIn: true,
+		Permissions: "player.control,controls.set,presets.load,recorder.manage,automation.run"},
+	{Name: "volunteer", Description: "Volunteer: player only", BuiltIn: true,
+		Permissions: "player.control"},
+}
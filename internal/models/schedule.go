@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ScheduledService is a planned celebration or event (mass, wedding,
+// concert). Weekly services repeat at the same weekday and time.
+type ScheduledService struct {
+	gorm.Model
+	Name            string    `gorm:"not null"`
+	StartsAt        time.Time `gorm:"index"`
+	DurationMinutes int
+	Weekly          bool
+	PresetID        string // preset normally used for the service, optional
+	Notes           string
+}
@@ -15,5 +15,17 @@ type Session struct {
 	DeviceInfo       string
 	IPAddress        string
 	AccessMethod     string    // local, remote
-	ExpiresAt        time.Time `gorm:"index"`
+	ExpiresAt        time.Time `gorm:"index"` // access token expiry
+	RefreshExpiresAt time.Time `gorm:"index"` // the session ends here unless refreshed
+	LastActivity     time.Time // refreshed by the auth middleware, at most once a minute
+}
+
+// UsedRefreshToken remembers a refresh token that was already rotated.
+// Presenting it again means it was stolen, so its session is revoked.
+type UsedRefreshToken struct {
+	TokenHash string `gorm:"primaryKey"`
+	SessionID string `gorm:"index"`
+	UserID    string
+	UsedAt    time.Time
+	ExpiresAt time.Time `gorm:"index"` // kept until the token would have expired anyway
 }
@@ -0,0 +1,9 @@
+package models
+
+// SongTag is a user-defined label ("Comunione", "Avvento") attached to a
+// song by name, so tags survive the daemon renumbering its song IDs.
+type SongTag struct {
+	ID       uint   `gorm:"primaryKey"`
+	SongName string `gorm:"uniqueIndex:idx_song_tag;not null"`
+	Tag      string `gorm:"uniqueIndex:idx_song_tag;index;not null"`
+}
@@ -11,10 +11,18 @@ type User struct {
 	ID           string `gorm:"primaryKey"`
 	Username     string `gorm:"uniqueIndex;not null"`
 	PasswordHash string `gorm:"not null"`
-	Role         string `gorm:"not null"` // admin, priest, technician, volunteer
+	Role         string `gorm:"not null"` // name of a models.Role (admin, user, priest, technician, volunteer, ...)
 	FullName     string `gorm:"not null"`
 	Email        string
 	LastLogin    *time.Time
 	IsActive     bool `gorm:"default:true"`
 	CreatedBy    string
+
+	MustChangePassword bool // set by an admin reset, cleared by POST /api/auth/password
+	PasswordChangedAt  *time.Time
+
+	// Login guard: consecutive failures since the last successful login
+	FailedLogins    int
+	LastFailedLogin *time.Time
+	LockedUntil     *time.Time
 }
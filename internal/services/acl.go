@@ -0,0 +1,122 @@
+package services
+
+import (
+	"av-control/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Access rule values
+const (
+	SubjectRole = "role"
+	SubjectUser = "user"
+
+	ResourceControl = "control"
+	ResourcePreset  = "preset"
+	ResourceSource  = "source"
+
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+
+	anyResource = "*"
+)
+
+var ErrInvalidAccessRule = errors.New("invalid access rule")
+
+// AccessControl evaluates the per-resource access rules on top of the role
+// permissions. Without any matching rule a resource is allowed, so an
+// allow-list is written as a "*" deny plus the allowed IDs.
+//
+// The most specific level with a matching rule decides, in this order:
+// user + ID, user + "*", role + ID, role + "*". Within a level deny wins.
+// Admins are never restricted.
+type AccessControl struct {
+	db *gorm.DB
+
+	mu    sync.RWMutex
+	rules []models.AccessRule
+}
+
+func NewAccessControl(db *gorm.DB) *AccessControl {
+	acl := &AccessControl{db: db}
+	acl.Reload()
+	return acl
+}
+
+// Reload reads the rules again after a change.
+func (a *AccessControl) Reload() {
+	var rules []models.AccessRule
+	if err := a.db.Find(&rules).Error; err != nil {
+		log.Printf("⚠️  Failed to load access rules: %v", err)
+		return
+	}
+
+	a.mu.Lock()
+	a.rules = rules
+	a.mu.Unlock()
+}
+
+// Allowed reports whether the user may use the resource.
+func (a *AccessControl) Allowed(userID, role, resourceType, resourceID string) bool {
+	if role == AdminRole {
+		return true
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	levels := []struct{ subjectType, subject, id string }{
+		{SubjectUser, userID, resourceID},
+		{SubjectUser, userID, anyResource},
+		{SubjectRole, role, resourceID},
+		{SubjectRole, role, anyResource},
+	}
+	for _, level := range levels {
+		matched, denied := false, false
+		for _, r := range a.rules {
+			if r.ResourceType != resourceType || r.SubjectType != level.subjectType ||
+				r.Subject != level.subject || r.ResourceID != level.id {
+				continue
+			}
+			matched = true
+			if r.Effect == EffectDeny {
+				denied = true
+			}
+		}
+		if matched {
+			return !denied
+		}
+	}
+	return true
+}
+
+// Validate checks a rule before it is saved.
+func (a *AccessControl) Validate(r *models.AccessRule) error {
+	switch {
+	case r.SubjectType != SubjectRole && r.SubjectType != SubjectUser:
+		return fmt.Errorf("%w: subject_type must be %q or %q", ErrInvalidAccessRule, SubjectRole, SubjectUser)
+	case r.Subject == "":
+		return fmt.Errorf("%w: subject is required", ErrInvalidAccessRule)
+	case r.ResourceType != ResourceControl && r.ResourceType != ResourcePreset && r.ResourceType != ResourceSource:
+		return fmt.Errorf("%w: resource_type must be %q, %q or %q", ErrInvalidAccessRule, ResourceControl, ResourcePreset, ResourceSource)
+	case r.ResourceID == "":
+		return fmt.Errorf("%w: resource_id is required (\"*\" for all)", ErrInvalidAccessRule)
+	case r.Effect != EffectAllow && r.Effect != EffectDeny:
+		return fmt.Errorf("%w: effect must be %q or %q", ErrInvalidAccessRule, EffectAllow, EffectDeny)
+	}
+
+	var count int64
+	if r.SubjectType == SubjectRole {
+		a.db.Model(&models.Role{}).Where("name = ?", r.Subject).Count(&count)
+	} else {
+		a.db.Model(&models.User{}).Where("id = ?", r.Subject).Count(&count)
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %s %q does not exist", ErrInvalidAccessRule, r.SubjectType, r.Subject)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"av-control/internal/models"
+	"testing"
+)
+
+func TestAccessControlAllowed(t *testing.T) {
+	rule := func(subjectType, subject, resourceType, resourceID, effect string) models.AccessRule {
+		return models.AccessRule{
+			SubjectType:  subjectType,
+			Subject:      subject,
+			ResourceType: resourceType,
+			ResourceID:   resourceID,
+			Effect:       effect,
+		}
+	}
+
+	acl := &AccessControl{rules: []models.AccessRule{
+		// Volunteers may only use the two microphones
+		rule(SubjectRole, "volunteer", ResourceControl, "*", EffectDeny),
+		rule(SubjectRole, "volunteer", ResourceControl, "100", EffectAllow),
+		rule(SubjectRole, "volunteer", ResourceControl, "101", EffectAllow),
+		// ... except Marco, who also gets the organ
+		rule(SubjectUser, "marco", ResourceControl, "200", EffectAllow),
+		// ... and Anna, who gets no control at all
+		rule(SubjectUser, "anna", ResourceControl, "*", EffectDeny),
+
+		// Priests may not load the test preset
+		rule(SubjectRole, "priest", ResourcePreset, "test.smix", EffectDeny),
+
+		// Conflicting rules on the same level: deny wins
+		rule(SubjectRole, "technician", ResourceSource, "3", EffectAllow),
+		rule(SubjectRole, "technician", ResourceSource, "3", EffectDeny),
+
+		// A user deny on one ID beats a role allow on it
+		rule(SubjectRole, "user", ResourcePreset, "*", EffectAllow),
+		rule(SubjectUser, "luca", ResourcePreset, "chiusura.smix", EffectDeny),
+	}}
+
+	tests := []struct {
+		name         string
+		userID       string
+		role         string
+		resourceType string
+		resourceID   string
+		want         bool
+	}{
+		{"no rules for the role", "u1", "user", ResourceControl, "100", true},
+		{"role allow-list entry", "u2", "volunteer", ResourceControl, "100", true},
+		{"role wildcard deny", "u2", "volunteer", ResourceControl, "300", false},
+		{"user allow beats role wildcard deny", "marco", "volunteer", ResourceControl, "200", true},
+		{"user still gets the role allow-list", "marco", "volunteer", ResourceControl, "101", true},
+		{"user wildcard deny beats role allow", "anna", "volunteer", ResourceControl, "100", false},
+		{"rules are per resource type", "u2", "volunteer", ResourcePreset, "100", true},
+		{"role deny on one ID", "u3", "priest", ResourcePreset, "test.smix", false},
+		{"role deny leaves other IDs", "u3", "priest", ResourcePreset, "messa.smix", true},
+		{"deny wins within a level", "u4", "technician", ResourceSource, "3", false},
+		{"user ID deny beats role wildcard allow", "luca", "user", ResourcePreset, "chiusura.smix", false},
+		{"role wildcard allow", "luca", "user", ResourcePreset, "messa.smix", true},
+		{"admins are never restricted", "anna", AdminRole, ResourceControl, "100", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acl.Allowed(tt.userID, tt.role, tt.resourceType, tt.resourceID); got != tt.want {
+				t.Errorf("Allowed(%q, %q, %q, %q) = %v, want %v", tt.userID, tt.role, tt.resourceType, tt.resourceID, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"av-control/internal/hardware"
+	"av-control/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Action types understood by the ActionExecutor
+const (
+	ActionSetControl    = "set_control"
+	ActionSelectSource  = "select_source"
+	ActionSelectSong    = "select_song"
+	ActionPlay          = "play"
+	ActionPause         = "pause"
+	ActionStop          = "stop"
+	ActionLoadPreset    = "load_preset"
+	ActionApplySnapshot = "apply_snapshot"
+	ActionRunMacro      = "run_macro"
+	ActionDelay         = "delay"
+)
+
+const (
+	maxActionDelay = 30 * time.Second
+	maxMacroDepth  = 3
+)
+
+var ErrInvalidAction = errors.New("invalid action")
+
+// Action is one step of a preset hook, macro or rule.
+type Action struct {
+	Type      string      `json:"type"`
+	ControlID string      `json:"control_id,omitempty"` // set_control
+	Value     interface{} `json:"value,omitempty"`      // set_control: number (volume) or bool (mute)
+	SourceID  *int        `json:"source_id,omitempty"`  // select_source
+	SongID    *int        `json:"song_id,omitempty"`    // select_song
+	PresetID  string      `json:"preset_id,omitempty"`  // load_preset
+	Snapshot  string      `json:"snapshot,omitempty"`   // apply_snapshot (name)
+	Macro     string      `json:"macro,omitempty"`      // run_macro (name)
+	DelayMs   int         `json:"delay_ms,omitempty"`   // delay
+}
+
+// ActionResult reports the outcome of a single executed action.
+type ActionResult struct {
+	Type    string `json:"type"`
+	Target  string `json:"target,omitempty"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ControlState is the captured state of one mixer control.
+type ControlState struct {
+	ID     string   `json:"id"`
+	Volume *float64 `json:"volume,omitempty"`
+	Mute   *bool    `json:"mute,omitempty"`
+}
+
+// ActionExecutor runs actions against the hardware. Execution continues
+// after a failed action so one missing control does not skip the rest.
+type ActionExecutor struct {
+	db       *gorm.DB
+	hwClient hardware.HardwareClient
+	songs    *SongIndex
+	guard    func() error
+}
+
+func NewActionExecutor(db *gorm.DB, hwClient hardware.HardwareClient, songs *SongIndex) *ActionExecutor {
+	return &ActionExecutor{db: db, hwClient: hwClient, songs: songs}
+}
+
+// SetGuard installs a check run before every action; while it returns an
+// error no action is executed. Must be called before the executor is used.
+func (e *ActionExecutor) SetGuard(guard func() error) {
+	e.guard = guard
+}
+
+// Validate checks that every action is well formed.
+func (e *ActionExecutor) Validate(actions []Action) error {
+	for i, a := range actions {
+		var err error
+		switch a.Type {
+		case ActionSetControl:
+			if a.ControlID == "" {
+				err = errors.New("control_id is required")
+			} else if _, ok := controlValue(a.Value); !ok {
+				err = errors.New("value must be a number or a boolean")
+			}
+		case ActionSelectSource:
+			if a.SourceID == nil {
+				err = errors.New("source_id is required")
+			}
+		case ActionSelectSong:
+			if a.SongID == nil {
+				err = errors.New("song_id is required")
+			}
+		case ActionLoadPreset:
+			if a.PresetID == "" {
+				err = errors.New("preset_id is required")
+			}
+		case ActionApplySnapshot:
+			if a.Snapshot == "" {
+				err = errors.New("snapshot is required")
+			}
+		case ActionRunMacro:
+			if a.Macro == "" {
+				err = errors.New("macro is required")
+			}
+		case ActionDelay:
+			if a.DelayMs <= 0 || time.Duration(a.DelayMs)*time.Millisecond > maxActionDelay {
+				err = fmt.Errorf("delay_ms must be between 1 and %d", maxActionDelay.Milliseconds())
+			}
+		case ActionPlay, ActionPause, ActionStop:
+		default:
+			err = fmt.Errorf("unknown action type %q", a.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("%w %d (%s): %v", ErrInvalidAction, i+1, a.Type, err)
+		}
+	}
+	return nil
+}
+
+// Run executes the actions in order and reports each result.
+func (e *ActionExecutor) Run(actions []Action) []ActionResult {
+	return e.run(actions, 0)
+}
+
+func (e *ActionExecutor) run(actions []Action, depth int) []ActionResult {
+	results := make([]ActionResult, 0, len(actions))
+	for _, a := range actions {
+		if a.Type == ActionRunMacro {
+			results = append(results, e.runMacro(a.Macro, depth)...)
+			continue
+		}
+
+		if e.guard != nil {
+			if err := e.guard(); err != nil {
+				results = append(results, actionResult(a.Type, "", err))
+				continue
+			}
+		}
+
+		target, err := e.execute(a)
+		results = append(results, actionResult(a.Type, target, err))
+	}
+	return results
+}
+
+func (e *ActionExecutor) execute(a Action) (string, error) {
+	switch a.Type {
+	case ActionSetControl:
+		value, ok := controlValue(a.Value)
+		if !ok {
+			return a.ControlID, errors.New("invalid control value")
+		}
+		return a.ControlID, e.hwClient.SetControlValue(a.ControlID, value)
+	case ActionSelectSource:
+		if a.SourceID == nil {
+			return "", errors.New("source_id is required")
+		}
+		if err := e.hwClient.SelectSource(*a.SourceID); err != nil {
+			return strconv.Itoa(*a.SourceID), err
+		}
+		if e.songs != nil {
+			e.songs.SourceSelected(*a.SourceID)
+		}
+		return strconv.Itoa(*a.SourceID), nil
+	case ActionSelectSong:
+		if a.SongID == nil {
+			return "", errors.New("song_id is required")
+		}
+		return strconv.Itoa(*a.SongID), e.hwClient.SelectSong(*a.SongID)
+	case ActionPlay:
+		return "", e.hwClient.Play()
+	case ActionPause:
+		return "", e.hwClient.Pause()
+	case ActionStop:
+		return "", e.hwClient.Stop()
+	case ActionLoadPreset:
+		return a.PresetID, e.hwClient.LoadPreset(a.PresetID)
+	case ActionApplySnapshot:
+		return a.Snapshot, e.ApplySnapshot(a.Snapshot)
+	case ActionDelay:
+		d := time.Duration(a.DelayMs) * time.Millisecond
+		if d > maxActionDelay {
+			d = maxActionDelay
+		}
+		time.Sleep(d)
+		return "", nil
+	default:
+		return "", fmt.Errorf("unknown action type %q", a.Type)
+	}
+}
+
+func (e *ActionExecutor) runMacro(name string, depth int) []ActionResult {
+	failed := func(err error) []ActionResult {
+		return []ActionResult{{Type: ActionRunMacro, Target: name, Success: false, Error: err.Error()}}
+	}
+
+	if depth >= maxMacroDepth {
+		return failed(errors.New("macros nested too deeply"))
+	}
+
+	var macro models.Macro
+	if err := e.db.Where("name = ?", name).First(&macro).Error; err != nil {
+		return failed(fmt.Errorf("macro %q not found", name))
+	}
+	actions, err := DecodeActions(macro.Actions)
+	if err != nil {
+		return failed(err)
+	}
+
+	results := []ActionResult{{Type: ActionRunMacro, Target: name, Success: true}}
+	return append(results, e.run(actions, depth+1)...)
+}
+
+// CaptureControls reads the current state of the given controls (all
+// controls when ids is empty).
+func (e *ActionExecutor) CaptureControls(ids []string) ([]ControlState, error) {
+	if len(ids) == 0 {
+		controls, err := e.hwClient.GetControls()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range controls.Controls {
+			ids = append(ids, strconv.Itoa(c.ID))
+		}
+	}
+
+	muteReader, _ := e.hwClient.(hardware.MuteReader)
+
+	states := make([]ControlState, 0, len(ids))
+	for _, id := range ids {
+		state := ControlState{ID: id}
+		if value, err := e.hwClient.GetControlValue(id); err == nil {
+			switch v := value.Value.(type) {
+			case float64:
+				state.Volume = &v
+			case bool:
+				state.Mute = &v
+			}
+		}
+		if muteReader != nil && state.Mute == nil {
+			if mute, err := muteReader.GetControlMute(id); err == nil {
+				state.Mute = &mute
+			}
+		}
+		if state.Volume != nil || state.Mute != nil {
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+// RestoreControls writes captured states back, returning the first error.
+func (e *ActionExecutor) RestoreControls(states []ControlState) error {
+	var firstErr error
+	for _, s := range states {
+		if s.Volume != nil {
+			if err := e.hwClient.SetControlValue(s.ID, *s.Volume); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		if s.Mute != nil {
+			if err := e.hwClient.SetControlValue(s.ID, *s.Mute); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// ApplySnapshot restores the controls stored in the named snapshot.
+func (e *ActionExecutor) ApplySnapshot(name string) error {
+	var snapshot models.Snapshot
+	if err := e.db.Where("name = ?", name).First(&snapshot).Error; err != nil {
+		return fmt.Errorf("snapshot %q not found", name)
+	}
+
+	var states []ControlState
+	if err := json.Unmarshal([]byte(snapshot.Controls), &states); err != nil {
+		return fmt.Errorf("snapshot %q is corrupted: %w", name, err)
+	}
+	return e.RestoreControls(states)
+}
+
+// DecodeActions parses a JSON action list stored in the database.
+func DecodeActions(data string) ([]Action, error) {
+	if data == "" {
+		return []Action{}, nil
+	}
+	var actions []Action
+	if err := json.Unmarshal([]byte(data), &actions); err != nil {
+		return nil, fmt.Errorf("invalid action list: %w", err)
+	}
+	return actions, nil
+}
+
+// EncodeActions is the inverse of DecodeActions.
+func EncodeActions(actions []Action) string {
+	if actions == nil {
+		actions = []Action{}
+	}
+	data, _ := json.Marshal(actions)
+	return string(data)
+}
+
+// controlValue normalizes a JSON value for SetControlValue.
+func controlValue(v interface{}) (interface{}, bool) {
+	switch value := v.(type) {
+	case float64, bool:
+		return value, true
+	case int:
+		return float64(value), true
+	default:
+		return nil, false
+	}
+}
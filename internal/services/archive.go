@@ -0,0 +1,313 @@
+package services
+
+import (
+	"av-control/internal/hardware"
+	"av-control/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Archive status values stored on models.Recording
+const (
+	ArchivePending   = "pending"
+	ArchiveRunning   = "archiving"
+	ArchiveDone      = "archived"
+	ArchiveFailed    = "failed"
+	archiveQueueSize = 100
+
+	// A stopped recording may still be flushed to disk: its size must stay
+	// the same for archiveSettleDelay before it is hashed and uploaded
+	archiveSettleDelay   = 5 * time.Second
+	archiveSettleTimeout = 2 * time.Minute
+)
+
+var ErrArchiveNotFailed = errors.New("recording archival has not failed")
+
+// Archiver copies finished recordings to an ArchiveTarget, verifying the
+// checksum and retrying with exponential backoff.
+type Archiver struct {
+	db            *gorm.DB
+	hwClient      hardware.HardwareClient
+	target        ArchiveTarget
+	recordingsDir string
+	maxAttempts   int
+	retryDelay    time.Duration
+
+	queue    chan uint
+	stopChan chan struct{}
+}
+
+func NewArchiver(db *gorm.DB, hwClient hardware.HardwareClient, target ArchiveTarget, recordingsDir string, maxAttempts int, retryDelay time.Duration) *Archiver {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &Archiver{
+		db:            db,
+		hwClient:      hwClient,
+		target:        target,
+		recordingsDir: recordingsDir,
+		maxAttempts:   maxAttempts,
+		retryDelay:    retryDelay,
+		queue:         make(chan uint, archiveQueueSize),
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Enabled reports whether an archive target is configured.
+func (a *Archiver) Enabled() bool {
+	return a != nil && a.target != nil
+}
+
+func (a *Archiver) Start() {
+	if !a.Enabled() {
+		log.Println("📦 Archiving disabled (ARCHIVE_TARGET not set)")
+		return
+	}
+	go a.worker()
+
+	// Resume work interrupted by a restart
+	var pending []models.Recording
+	a.db.Where("archive_status IN ?", []string{ArchivePending, ArchiveRunning}).Find(&pending)
+	for _, rec := range pending {
+		a.enqueue(rec.ID)
+	}
+	log.Printf("📦 Archiver started (%s, %d pending)", a.target.Name(), len(pending))
+}
+
+func (a *Archiver) Stop() {
+	if a.Enabled() {
+		close(a.stopChan)
+	}
+}
+
+// OnRecordingFinished is registered on the RecordingCatalog: every stopped
+// recording is queued for archival once the device had time to close the file.
+func (a *Archiver) OnRecordingFinished(rec *models.Recording) {
+	if !a.Enabled() {
+		return
+	}
+	if err := a.markPending(rec.ID); err != nil {
+		log.Printf("⚠️  Failed to queue recording %d for archival: %v", rec.ID, err)
+		return
+	}
+	a.enqueueAfter(rec.ID, archiveSettleDelay)
+}
+
+// Archive (re)queues a recording, resetting its attempt counter.
+func (a *Archiver) Archive(id uint) error {
+	if !a.Enabled() {
+		return errors.New("archiving is not configured")
+	}
+	if err := a.markPending(id); err != nil {
+		return err
+	}
+	a.enqueue(id)
+	return nil
+}
+
+// Clear gives up on a failed archival, so retention may delete the file.
+func (a *Archiver) Clear(id uint) error {
+	result := a.db.Model(&models.Recording{}).
+		Where("id = ? AND archive_status = ?", id, ArchiveFailed).
+		Updates(map[string]interface{}{"archive_status": "", "archive_error": ""})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrArchiveNotFailed
+	}
+	return nil
+}
+
+func (a *Archiver) markPending(id uint) error {
+	return a.db.Model(&models.Recording{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"archive_status":   ArchivePending,
+		"archive_target":   a.target.Name(),
+		"archive_attempts": 0,
+		"archive_error":    "",
+	}).Error
+}
+
+func (a *Archiver) enqueue(id uint) {
+	select {
+	case a.queue <- id:
+	default:
+		// The startup scan picks it up again after a restart
+		log.Printf("⚠️  Archive queue full, recording %d stays pending", id)
+	}
+}
+
+func (a *Archiver) worker() {
+	for {
+		select {
+		case id := <-a.queue:
+			a.process(id)
+		case <-a.stopChan:
+			return
+		}
+	}
+}
+
+func (a *Archiver) process(id uint) {
+	var rec models.Recording
+	if err := a.db.First(&rec, id).Error; err != nil {
+		return
+	}
+	if rec.ArchiveStatus == ArchiveDone {
+		return
+	}
+
+	attempt := rec.ArchiveAttempts + 1
+	a.db.Model(&rec).Updates(map[string]interface{}{
+		"archive_status":   ArchiveRunning,
+		"archive_attempts": attempt,
+	})
+
+	checksum, err := a.upload(&rec)
+	if err == nil {
+		now := time.Now()
+		a.db.Model(&rec).Updates(map[string]interface{}{
+			"archive_status":   ArchiveDone,
+			"archive_checksum": checksum,
+			"archived_at":      &now,
+			"archive_error":    "",
+		})
+		log.Printf("📦 Archived %s to %s (sha256 %s)", rec.Filename, a.target.Name(), checksum[:12])
+		return
+	}
+
+	if attempt >= a.maxAttempts {
+		a.db.Model(&rec).Updates(map[string]interface{}{
+			"archive_status": ArchiveFailed,
+			"archive_error":  err.Error(),
+		})
+		log.Printf("❌ Archiving %s failed after %d attempts: %v", rec.Filename, attempt, err)
+		return
+	}
+
+	delay := a.retryDelay * time.Duration(1<<(attempt-1))
+	a.db.Model(&rec).Updates(map[string]interface{}{
+		"archive_status": ArchivePending,
+		"archive_error":  err.Error(),
+	})
+	log.Printf("⚠️  Archiving %s failed (attempt %d/%d), retrying in %s: %v", rec.Filename, attempt, a.maxAttempts, delay, err)
+	a.enqueueAfter(id, delay)
+}
+
+func (a *Archiver) enqueueAfter(id uint, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		select {
+		case <-a.stopChan:
+		default:
+			a.enqueue(id)
+		}
+	})
+}
+
+// upload locates the recorded file (recordings directory first, daemon
+// second), hashes it and hands it to the target.
+func (a *Archiver) upload(rec *models.Recording) (string, error) {
+	name := filepath.Base(rec.Filename)
+
+	localPath, cleanup, err := a.localCopy(name)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	if err := a.waitStable(localPath); err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	checksum, err := fileSHA256(f)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	if err := a.target.Put(ctx, name, localPath, checksum); err != nil {
+		return "", err
+	}
+	return checksum, nil
+}
+
+// waitStable waits until the file size and modification time have not changed
+// for archiveSettleDelay.
+func (a *Archiver) waitStable(p string) error {
+	deadline := time.Now().Add(archiveSettleTimeout)
+	var lastSize int64 = -1
+	var lastMod, since time.Time
+
+	for {
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if info.Size() != lastSize || !info.ModTime().Equal(lastMod) {
+			lastSize, lastMod, since = info.Size(), info.ModTime(), now
+		} else if now.Sub(since) >= archiveSettleDelay {
+			return nil
+		}
+		if now.After(deadline) {
+			return fmt.Errorf("recording file %s is still being written", filepath.Base(p))
+		}
+
+		select {
+		case <-time.After(time.Second):
+		case <-a.stopChan:
+			return errors.New("archiver stopped")
+		}
+	}
+}
+
+func (a *Archiver) localCopy(name string) (string, func(), error) {
+	noop := func() {}
+
+	if a.recordingsDir != "" {
+		p := filepath.Join(a.recordingsDir, name)
+		if _, err := os.Stat(p); err == nil {
+			return p, noop, nil
+		}
+	}
+
+	source, ok := a.hwClient.(hardware.RecordingFileSource)
+	if !ok {
+		return "", noop, fmt.Errorf("recording file %s not found", name)
+	}
+
+	resp, err := source.OpenRecording(name, "")
+	if err != nil {
+		return "", noop, err
+	}
+	defer resp.Body.Close()
+
+	tmp, err := os.CreateTemp("", "av-archive-*-"+name)
+	if err != nil {
+		return "", noop, err
+	}
+	cleanup := func() { os.Remove(tmp.Name()) }
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		cleanup()
+		return "", noop, err
+	}
+	tmp.Close()
+	return tmp.Name(), cleanup, nil
+}
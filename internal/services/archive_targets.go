@@ -0,0 +1,464 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// ArchiveTarget stores a finished recording off the device. Put must only
+// return nil once the target holds a copy whose SHA-256 equals checksum.
+type ArchiveTarget interface {
+	Name() string
+	Put(ctx context.Context, name string, localPath string, checksum string) error
+}
+
+type ArchiveConfig struct {
+	Target string // local, sftp, s3 (empty = archiving disabled)
+
+	LocalPath string
+
+	SFTPAddr       string
+	SFTPUser       string
+	SFTPPassword   string
+	SFTPKeyFile    string
+	SFTPKnownHosts string
+	SFTPInsecure   bool // skip host key verification, only when explicitly asked
+	SFTPPath       string
+
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3Prefix    string
+	S3AccessKey string
+	S3SecretKey string
+}
+
+var errChecksumMismatch = errors.New("checksum mismatch after upload")
+
+// NewArchiveTarget builds the target selected in cfg. It returns nil, nil
+// when archiving is disabled.
+func NewArchiveTarget(cfg ArchiveConfig) (ArchiveTarget, error) {
+	switch cfg.Target {
+	case "":
+		return nil, nil
+	case "local":
+		if cfg.LocalPath == "" {
+			return nil, errors.New("ARCHIVE_LOCAL_PATH is required for the local target")
+		}
+		return &localTarget{dir: cfg.LocalPath}, nil
+	case "sftp":
+		if cfg.SFTPAddr == "" || cfg.SFTPUser == "" {
+			return nil, errors.New("ARCHIVE_SFTP_ADDR and ARCHIVE_SFTP_USER are required for the sftp target")
+		}
+		return newSFTPTarget(cfg)
+	case "s3":
+		if cfg.S3Endpoint == "" || cfg.S3Bucket == "" {
+			return nil, errors.New("ARCHIVE_S3_ENDPOINT and ARCHIVE_S3_BUCKET are required for the s3 target")
+		}
+		region := cfg.S3Region
+		if region == "" {
+			region = "us-east-1"
+		}
+		return &s3Target{
+			endpoint:  strings.TrimRight(cfg.S3Endpoint, "/"),
+			region:    region,
+			bucket:    cfg.S3Bucket,
+			prefix:    cfg.S3Prefix,
+			accessKey: cfg.S3AccessKey,
+			secretKey: cfg.S3SecretKey,
+			client:    &http.Client{},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown archive target %q (use local, sftp or s3)", cfg.Target)
+	}
+}
+
+// fileSHA256 returns the hex SHA-256 of a reader's content.
+func fileSHA256(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ============================================================================
+// LOCAL PATH / NAS MOUNT
+// ============================================================================
+
+type localTarget struct {
+	dir string
+}
+
+func (t *localTarget) Name() string {
+	return "local:" + t.dir
+}
+
+func (t *localTarget) Put(ctx context.Context, name string, localPath string, checksum string) error {
+	if err := os.MkdirAll(t.dir, 0750); err != nil {
+		return err
+	}
+
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest := filepath.Join(t.dir, name)
+	tmp := dest + ".part"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	out.Close()
+
+	// Verify what actually landed on the target
+	written, err := os.Open(tmp)
+	if err != nil {
+		return err
+	}
+	sum, err := fileSHA256(written)
+	written.Close()
+	if err != nil || sum != checksum {
+		os.Remove(tmp)
+		if err != nil {
+			return err
+		}
+		return errChecksumMismatch
+	}
+
+	return os.Rename(tmp, dest)
+}
+
+// ============================================================================
+// SFTP
+// ============================================================================
+
+type sftpTarget struct {
+	addr   string
+	dir    string
+	config *ssh.ClientConfig
+}
+
+func newSFTPTarget(cfg ArchiveConfig) (*sftpTarget, error) {
+	var auth []ssh.AuthMethod
+	if cfg.SFTPKeyFile != "" {
+		key, err := os.ReadFile(cfg.SFTPKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ARCHIVE_SFTP_KEY_FILE: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("parse ARCHIVE_SFTP_KEY_FILE: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.SFTPPassword != "" {
+		auth = append(auth, ssh.Password(cfg.SFTPPassword))
+	}
+
+	var hostKeyCallback ssh.HostKeyCallback
+	switch {
+	case cfg.SFTPKnownHosts != "":
+		cb, err := knownhosts.New(cfg.SFTPKnownHosts)
+		if err != nil {
+			return nil, fmt.Errorf("read ARCHIVE_SFTP_KNOWN_HOSTS: %w", err)
+		}
+		hostKeyCallback = cb
+	case cfg.SFTPInsecure:
+		log.Println("⚠️  ARCHIVE_SFTP_INSECURE=true, SFTP host key will not be verified")
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, errors.New("ARCHIVE_SFTP_KNOWN_HOSTS is required for the sftp target (or ARCHIVE_SFTP_INSECURE=true to skip host key verification)")
+	}
+
+	addr := cfg.SFTPAddr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	return &sftpTarget{
+		addr: addr,
+		dir:  cfg.SFTPPath,
+		config: &ssh.ClientConfig{
+			User:            cfg.SFTPUser,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         15 * time.Second,
+		},
+	}, nil
+}
+
+func (t *sftpTarget) Name() string {
+	return "sftp:" + t.addr + ":" + t.dir
+}
+
+func (t *sftpTarget) Put(ctx context.Context, name string, localPath string, checksum string) error {
+	conn, err := ssh.Dial("tcp", t.addr, t.config)
+	if err != nil {
+		return fmt.Errorf("ssh connect: %w", err)
+	}
+	defer conn.Close()
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		return fmt.Errorf("sftp session: %w", err)
+	}
+	defer client.Close()
+
+	if t.dir != "" {
+		if err := client.MkdirAll(t.dir); err != nil {
+			return err
+		}
+	}
+
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest := path.Join(t.dir, name)
+	tmp := dest + ".part"
+	out, err := client.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := out.ReadFrom(src); err != nil {
+		out.Close()
+		client.Remove(tmp)
+		return err
+	}
+	out.Close()
+
+	// Read the remote copy back to verify it
+	remote, err := client.Open(tmp)
+	if err != nil {
+		return err
+	}
+	sum, err := fileSHA256(remote)
+	remote.Close()
+	if err != nil || sum != checksum {
+		client.Remove(tmp)
+		if err != nil {
+			return err
+		}
+		return errChecksumMismatch
+	}
+
+	client.Remove(dest)
+	return client.Rename(tmp, dest)
+}
+
+// ============================================================================
+// S3-COMPATIBLE (AWS, MinIO, ...)
+// ============================================================================
+
+// s3Target uploads with a single signed PUT (AWS Signature V4, path-style
+// URLs so MinIO works out of the box). The payload SHA-256 is part of the
+// signature, so the server rejects any upload whose content does not match.
+type s3Target struct {
+	endpoint  string
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+const emptyPayloadSHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func (t *s3Target) Name() string {
+	return "s3:" + t.endpoint + "/" + t.bucket + "/" + t.prefix
+}
+
+func (t *s3Target) objectURL(name string) string {
+	key := strings.TrimLeft(path.Join(t.prefix, name), "/")
+	return t.endpoint + "/" + url.PathEscape(t.bucket) + "/" + (&url.URL{Path: key}).EscapedPath()
+}
+
+func (t *s3Target) Put(ctx context.Context, name string, localPath string, checksum string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, t.objectURL(name), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("x-amz-meta-sha256", checksum)
+	if raw, err := hex.DecodeString(checksum); err == nil {
+		req.Header.Set("x-amz-checksum-sha256", base64.StdEncoding.EncodeToString(raw))
+	}
+	t.sign(req, checksum, time.Now().UTC())
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3 put: %w", err)
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3 put failed (HTTP %d): %s", resp.StatusCode, string(body))
+	}
+
+	// Confirm the object exists with the expected size and checksum
+	head, err := http.NewRequestWithContext(ctx, http.MethodHead, t.objectURL(name), nil)
+	if err != nil {
+		return err
+	}
+	t.sign(head, emptyPayloadSHA256, time.Now().UTC())
+	resp, err = t.client.Do(head)
+	if err != nil {
+		return fmt.Errorf("s3 head: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ContentLength != info.Size() {
+		return fmt.Errorf("s3 verification failed (HTTP %d, %d bytes)", resp.StatusCode, resp.ContentLength)
+	}
+	if stored := resp.Header.Get("x-amz-meta-sha256"); stored != "" && stored != checksum {
+		return errChecksumMismatch
+	}
+
+	return nil
+}
+
+// sign adds an AWS Signature V4 Authorization header. Every header already
+// set on req is signed along with host, so the x-amz-* metadata and checksum
+// headers are covered and cannot be altered in transit.
+func (t *s3Target) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "authorization" {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEncode(req.URL.Path, false),
+		awsCanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + t.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+t.secretKey), date)
+	key = hmacSHA256(key, t.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+t.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// awsURIEncode escapes s the way Signature V4 expects: everything but the
+// unreserved characters, and slashes too unless it is a path.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// awsCanonicalQuery sorts and escapes the query parameters.
+func awsCanonicalQuery(query url.Values) string {
+	var params []string
+	for name, values := range query {
+		for _, v := range values {
+			params = append(params, awsURIEncode(name, true)+"="+awsURIEncode(v, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
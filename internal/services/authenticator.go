@@ -0,0 +1,84 @@
+package services
+
+import (
+	"av-control/internal/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrUserInactive = errors.New("user not found or inactive")
+
+// Identity is the user behind a validated access token.
+type Identity struct {
+	UserID             string
+	Username           string
+	Role               string // the stored role, so a role change applies at once
+	SessionID          string
+	MustChangePassword bool
+}
+
+// Authenticator validates access tokens for the API middleware and the
+// WebSocket handshake: the signature, a live session holding the token and
+// an active user.
+type Authenticator struct {
+	keys *KeySet
+	db   *gorm.DB
+}
+
+func NewAuthenticator(keys *KeySet, db *gorm.DB) *Authenticator {
+	return &Authenticator{keys: keys, db: db}
+}
+
+// Authenticate returns the identity of an access token. Errors are
+// ErrInvalidKeyToken, ErrSessionNotFound or ErrUserInactive.
+func (a *Authenticator) Authenticate(tokenString string) (*Identity, error) {
+	// 1. Parse and validate JWT
+	claims, err := a.keys.Parse(tokenString, TokenAccess)
+	if err != nil {
+		return nil, ErrInvalidKeyToken
+	}
+	userID, _ := claims["user_id"].(string)
+	if userID == "" {
+		return nil, ErrInvalidKeyToken
+	}
+
+	// 2. Hash token and verify session exists in DB, so logout and
+	// revocation take effect at once
+	tokenHash := sha256.Sum256([]byte(tokenString))
+	var session models.Session
+	result := a.db.Where("token_hash = ? AND user_id = ? AND expires_at > ?", hex.EncodeToString(tokenHash[:]), userID, time.Now()).
+		Limit(1).Find(&session)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrSessionNotFound
+	}
+
+	// 3. Verify user still exists and is active
+	var user models.User
+	result = a.db.Where("id = ? AND is_active = ?", userID, true).Limit(1).Find(&user)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrUserInactive
+	}
+
+	// 4. Track activity for the session list, without a write per request
+	if time.Since(session.LastActivity) > time.Minute {
+		a.db.Model(&session).Update("last_activity", time.Now())
+	}
+
+	return &Identity{
+		UserID:             userID,
+		Username:           user.Username,
+		Role:               user.Role,
+		SessionID:          session.ID,
+		MustChangePassword: user.MustChangePassword,
+	}, nil
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"av-control/internal/hardware"
+	"av-control/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Background music interrupt modes
+const (
+	InterruptStop = "stop"
+	InterruptDuck = "duck"
+)
+
+// Interrupt reasons
+const (
+	InterruptService    = "service"
+	InterruptPresetLoad = "preset_load"
+)
+
+const backgroundMusicInterval = 15 * time.Second
+
+// BackgroundMusicState is the current state of the background music mode.
+type BackgroundMusicState struct {
+	Active      bool       `json:"active"` // music started by the server is on
+	WindowID    uint       `json:"window_id,omitempty"`
+	WindowName  string     `json:"window_name,omitempty"`
+	Interrupted bool       `json:"interrupted"`
+	Reason      string     `json:"reason,omitempty"`
+	ResumeAt    *time.Time `json:"resume_at,omitempty"`
+	Overridden  bool       `json:"overridden"` // an operator took over the player in this window
+}
+
+func (s BackgroundMusicState) equal(o BackgroundMusicState) bool {
+	sameResume := (s.ResumeAt == nil && o.ResumeAt == nil) ||
+		(s.ResumeAt != nil && o.ResumeAt != nil && s.ResumeAt.Equal(*o.ResumeAt))
+	s.ResumeAt, o.ResumeAt = nil, nil
+	return sameResume && s == o
+}
+
+// BackgroundMusic plays music automatically inside the configured windows,
+// stops or ducks it during scheduled services and after preset loads, and
+// resumes it afterwards. It never takes the player away from an operator:
+// if the player is busy when a window opens, or is stopped by hand, the
+// window is left alone until it ends. Player changes go through the
+// ActionExecutor, so the song index follows the source and nothing plays
+// during an emergency mute.
+type BackgroundMusic struct {
+	db       *gorm.DB
+	hwClient hardware.HardwareClient
+	actions  *ActionExecutor
+	hub      *Hub
+	schedule *Schedule
+
+	mu               sync.Mutex
+	state            BackgroundMusicState
+	windowKey        string
+	presetPauseUntil time.Time
+
+	wake     chan struct{}
+	stopChan chan struct{}
+}
+
+func NewBackgroundMusic(db *gorm.DB, hwClient hardware.HardwareClient, actions *ActionExecutor, hub *Hub, schedule *Schedule) *BackgroundMusic {
+	return &BackgroundMusic{
+		db:       db,
+		hwClient: hwClient,
+		actions:  actions,
+		hub:      hub,
+		schedule: schedule,
+		wake:     make(chan struct{}, 1),
+		stopChan: make(chan struct{}),
+	}
+}
+
+func (bm *BackgroundMusic) Start() {
+	go bm.loop()
+	log.Println("🎵 Background music scheduler started")
+}
+
+func (bm *BackgroundMusic) Stop() {
+	close(bm.stopChan)
+}
+
+// State returns a copy of the current state.
+func (bm *BackgroundMusic) State() BackgroundMusicState {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return bm.state
+}
+
+// Settings returns the stored settings, or the defaults.
+func (bm *BackgroundMusic) Settings() models.BackgroundMusicSettings {
+	settings := models.BackgroundMusicSettings{
+		ID:                   1,
+		InterruptMode:        InterruptStop,
+		DuckVolume:           -30,
+		PresetPauseMinutes:   5,
+		ServiceMarginMinutes: 10,
+	}
+	bm.db.Where("id = ?", 1).Limit(1).Find(&settings)
+	return settings
+}
+
+// SaveSettings validates and stores the settings, then re-evaluates.
+func (bm *BackgroundMusic) SaveSettings(settings *models.BackgroundMusicSettings) error {
+	if settings.InterruptMode != InterruptStop && settings.InterruptMode != InterruptDuck {
+		return fmt.Errorf("interrupt_mode must be %q or %q", InterruptStop, InterruptDuck)
+	}
+	if settings.PresetPauseMinutes < 0 || settings.ServiceMarginMinutes < 0 {
+		return errors.New("minutes must not be negative")
+	}
+
+	settings.ID = 1
+	if err := bm.db.Save(settings).Error; err != nil {
+		return err
+	}
+	bm.Wake()
+	return nil
+}
+
+// PresetLoaded interrupts the music for the configured pause: loading a
+// preset usually means a celebration is about to start.
+func (bm *BackgroundMusic) PresetLoaded(presetID string) {
+	settings := bm.Settings()
+
+	bm.mu.Lock()
+	bm.presetPauseUntil = time.Now().Add(time.Duration(settings.PresetPauseMinutes) * time.Minute)
+	bm.mu.Unlock()
+
+	bm.Wake()
+}
+
+// Wake re-evaluates immediately instead of waiting for the next tick.
+func (bm *BackgroundMusic) Wake() {
+	select {
+	case bm.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (bm *BackgroundMusic) loop() {
+	ticker := time.NewTicker(backgroundMusicInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			bm.evaluate(time.Now())
+		case <-bm.wake:
+			bm.evaluate(time.Now())
+		case <-bm.stopChan:
+			return
+		}
+	}
+}
+
+// musicStep is what an evaluation decided to do on the player. It runs
+// after the state lock is released, so State() never waits on the device.
+type musicStep struct {
+	name    string
+	actions []Action
+	start   bool   // starting the music: on failure the window is not active
+	done    string // logged once the actions succeeded
+}
+
+func (bm *BackgroundMusic) evaluate(now time.Time) {
+	settings := bm.Settings()
+	window, key := bm.activeWindow(now)
+
+	// Read before taking the lock too; nil when the player cannot tell
+	var status *models.PlayerStatus
+	if settings.Enabled && window != nil {
+		if s, err := bm.hwClient.GetPlayerStatus(); err == nil {
+			status = s
+		}
+	}
+
+	bm.mu.Lock()
+	before := bm.state
+	var step musicStep
+
+	switch {
+	case !settings.Enabled || window == nil:
+		if bm.state.Active && !(bm.state.Interrupted && settings.InterruptMode == InterruptStop) {
+			step = musicStep{name: "stop", actions: []Action{{Type: ActionStop}}}
+		}
+		bm.state = BackgroundMusicState{}
+		bm.windowKey = ""
+
+	default:
+		if key != bm.windowKey {
+			bm.windowKey = key
+			bm.state = BackgroundMusicState{Active: bm.state.Active, WindowID: window.ID, WindowName: window.Name}
+		}
+		if !bm.state.Overridden {
+			step = bm.runWindow(now, &settings, window, status)
+		}
+	}
+	bm.mu.Unlock()
+
+	if err := bm.run(step.actions); err != nil {
+		log.Printf("⚠️  Background music %s failed: %v", step.name, err)
+		if step.start {
+			bm.mu.Lock()
+			if bm.windowKey == key {
+				bm.state.Active = false
+			}
+			bm.mu.Unlock()
+		}
+	} else if step.done != "" {
+		log.Print(step.done)
+	}
+
+	after := bm.State()
+	if !after.equal(before) && bm.hub != nil {
+		bm.hub.BroadcastBackgroundMusic(after)
+	}
+}
+
+// runWindow updates the state of an open window and returns what to do on
+// the player. Called with bm.mu held.
+func (bm *BackgroundMusic) runWindow(now time.Time, settings *models.BackgroundMusicSettings, window *models.BackgroundMusicWindow, status *models.PlayerStatus) musicStep {
+	reason, resumeAt := bm.interruptReason(now, settings)
+
+	// Playing music that the server no longer finds playing was stopped by hand
+	if bm.state.Active && !bm.state.Interrupted && status != nil && status.State != "playing" {
+		bm.state.Active = false
+		bm.state.Overridden = true
+		log.Printf("🎵 Background music stopped by an operator, window %q left alone", window.Name)
+		return musicStep{}
+	}
+
+	if reason != "" {
+		bm.state.Reason = reason
+		bm.state.ResumeAt = resumeAt
+		if bm.state.Active && !bm.state.Interrupted {
+			bm.state.Interrupted = true
+			return musicStep{
+				name:    "interrupt",
+				actions: interruptActions(settings, window),
+				done:    fmt.Sprintf("🎵 Background music interrupted (%s, %s)", reason, settings.InterruptMode),
+			}
+		}
+		return musicStep{}
+	}
+	bm.state.Reason = ""
+	bm.state.ResumeAt = nil
+
+	switch {
+	case bm.state.Interrupted:
+		bm.state.Interrupted = false
+		return musicStep{
+			name:    "resume",
+			actions: resumeActions(settings, window, status),
+			done:    fmt.Sprintf("🎵 Background music resumed (%s)", window.Name),
+		}
+	case !bm.state.Active:
+		if status != nil && status.State == "playing" {
+			// Someone else is using the player: do not take it over
+			bm.state.Overridden = true
+			return musicStep{}
+		}
+		bm.state.Active = true
+		return musicStep{
+			name:    fmt.Sprintf("start (%s)", window.Name),
+			actions: startActions(window),
+			start:   true,
+			done:    fmt.Sprintf("🎵 Background music started (%s)", window.Name),
+		}
+	}
+	return musicStep{}
+}
+
+func (bm *BackgroundMusic) interruptReason(now time.Time, settings *models.BackgroundMusicSettings) (string, *time.Time) {
+	if now.Before(bm.presetPauseUntil) {
+		resumeAt := bm.presetPauseUntil
+		return InterruptPresetLoad, &resumeAt
+	}
+	if bm.schedule == nil {
+		return "", nil
+	}
+	if svc := bm.schedule.Current(now); svc != nil {
+		return InterruptService, &svc.EndsAt
+	}
+	margin := time.Duration(settings.ServiceMarginMinutes) * time.Minute
+	if margin > 0 {
+		if svc := bm.schedule.Upcoming(now, margin); svc != nil {
+			return InterruptService, &svc.EndsAt
+		}
+	}
+	return "", nil
+}
+
+func startActions(window *models.BackgroundMusicWindow) []Action {
+	sourceID := window.SourceID
+	actions := []Action{{Type: ActionSelectSource, SourceID: &sourceID}}
+	if window.SongID != nil {
+		actions = append(actions, Action{Type: ActionSelectSong, SongID: window.SongID})
+	}
+	if window.ControlID != "" {
+		actions = append(actions, Action{Type: ActionSetControl, ControlID: window.ControlID, Value: window.Volume})
+	}
+	return append(actions, Action{Type: ActionPlay})
+}
+
+func interruptActions(settings *models.BackgroundMusicSettings, window *models.BackgroundMusicWindow) []Action {
+	if settings.InterruptMode == InterruptDuck && window.ControlID != "" {
+		return []Action{{Type: ActionSetControl, ControlID: window.ControlID, Value: settings.DuckVolume}}
+	}
+	return []Action{{Type: ActionStop}}
+}
+
+func resumeActions(settings *models.BackgroundMusicSettings, window *models.BackgroundMusicWindow, status *models.PlayerStatus) []Action {
+	if settings.InterruptMode == InterruptDuck && window.ControlID != "" {
+		// A preset load may have reset the volume and stopped the player
+		actions := []Action{{Type: ActionSetControl, ControlID: window.ControlID, Value: window.Volume}}
+		if status != nil && status.State != "playing" {
+			actions = append(actions, Action{Type: ActionPlay})
+		}
+		return actions
+	}
+	return startActions(window)
+}
+
+// run executes the actions one at a time, stopping at the first failure so
+// the player does not start on the wrong source.
+func (bm *BackgroundMusic) run(actions []Action) error {
+	for _, a := range actions {
+		for _, r := range bm.actions.Run([]Action{a}) {
+			if !r.Success {
+				return fmt.Errorf("%s: %s", r.Type, r.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// activeWindow returns the enabled window covering now and a key that
+// identifies this occurrence (window + start day).
+func (bm *BackgroundMusic) activeWindow(now time.Time) (*models.BackgroundMusicWindow, string) {
+	var windows []models.BackgroundMusicWindow
+	if err := bm.db.Where("enabled = ?", true).Order("id").Find(&windows).Error; err != nil {
+		return nil, ""
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	today := int(now.Weekday())
+	yesterday := (today + 6) % 7
+
+	for i := range windows {
+		w := &windows[i]
+		start, errStart := ParseClock(w.StartTime)
+		end, errEnd := ParseClock(w.EndTime)
+		if errStart != nil || errEnd != nil {
+			continue
+		}
+
+		if start <= end {
+			if weekdayAllowed(w.Weekdays, today) && minute >= start && minute < end {
+				return w, fmt.Sprintf("%d@%s", w.ID, now.Format("2006-01-02"))
+			}
+			continue
+		}
+
+		// Crosses midnight
+		if weekdayAllowed(w.Weekdays, today) && minute >= start {
+			return w, fmt.Sprintf("%d@%s", w.ID, now.Format("2006-01-02"))
+		}
+		if weekdayAllowed(w.Weekdays, yesterday) && minute < end {
+			return w, fmt.Sprintf("%d@%s", w.ID, now.AddDate(0, 0, -1).Format("2006-01-02"))
+		}
+	}
+	return nil, ""
+}
+
+// ParseClock parses "HH:MM" into minutes after midnight.
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (use HH:MM)", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func weekdayAllowed(weekdays string, day int) bool {
+	if weekdays == "" {
+		return true
+	}
+	for _, d := range SplitTags(weekdays) {
+		if n, err := strconv.Atoi(strings.TrimSpace(d)); err == nil && n == day {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package services
+
+import "errors"
+
+func diskUsage(path string) (free, total uint64, err error) {
+	return 0, 0, errors.New("disk usage not supported on this platform")
+}
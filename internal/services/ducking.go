@@ -0,0 +1,460 @@
+package services
+
+import (
+	"av-control/internal/hardware"
+	"av-control/internal/models"
+	"encoding/json"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	rampStep         = 50 * time.Millisecond
+	duckingQueueSize = 64
+)
+
+// DuckingRuleState reports a rule and whether it is currently ducking.
+type DuckingRuleState struct {
+	RuleID     uint     `json:"rule_id"`
+	Name       string   `json:"name"`
+	Ducked     bool     `json:"ducked"`
+	OpenVoices []string `json:"open_voices"`
+}
+
+type duckingRule struct {
+	rule       models.DuckingRule
+	voices     []string
+	music      []string
+	voiceOpen  map[string]bool
+	ducked     bool
+	releasing  bool
+	originals  map[string]float64 // music volumes before ducking
+	generation int                // bumped to cancel a running ramp or release
+}
+
+// duckingChange is a duck or release decided by evaluate, carried out by
+// the worker without holding the lock.
+type duckingChange struct {
+	rule       *duckingRule
+	generation int
+	duck       bool
+	hold       time.Duration
+}
+
+// duckingCommand is a control change seen by the hub's command listener.
+type duckingCommand struct {
+	controlID string
+	value     interface{}
+}
+
+// Ducker lowers music controls while microphones are open. Mute changes
+// are seen immediately through the hub's command listener (SetControlValue
+// from any client) and, for changes made at the desk, by polling the
+// voice controls. A single worker handles both, so device I/O never runs on
+// the caller's goroutine nor while holding the lock.
+type Ducker struct {
+	db       *gorm.DB
+	hwClient hardware.HardwareClient
+	interval time.Duration
+
+	mu       sync.Mutex
+	rules    []*duckingRule
+	commands chan duckingCommand
+	stopChan chan struct{}
+}
+
+func NewDucker(db *gorm.DB, hwClient hardware.HardwareClient, interval time.Duration) *Ducker {
+	return &Ducker{
+		db:       db,
+		hwClient: hwClient,
+		interval: interval,
+		commands: make(chan duckingCommand, duckingQueueSize),
+		stopChan: make(chan struct{}),
+	}
+}
+
+func (d *Ducker) Start() {
+	d.Reload()
+	go d.worker()
+	log.Println("🎙️  Ducking service started")
+}
+
+// Stop restores every ducked control.
+func (d *Ducker) Stop() {
+	close(d.stopChan)
+
+	d.mu.Lock()
+	var restores []map[string]float64
+	for _, r := range d.rules {
+		restores = append(restores, d.release(r))
+	}
+	d.mu.Unlock()
+
+	for _, volumes := range restores {
+		d.setVolumes(volumes)
+	}
+}
+
+// Reload re-reads the rules. Controls ducked by the old rules are restored.
+func (d *Ducker) Reload() {
+	var rules []models.DuckingRule
+	if err := d.db.Where("enabled = ?", true).Find(&rules).Error; err != nil {
+		log.Printf("❌ Failed to load ducking rules: %v", err)
+		return
+	}
+
+	d.mu.Lock()
+	var restores []map[string]float64
+	for _, r := range d.rules {
+		restores = append(restores, d.release(r))
+	}
+
+	d.rules = nil
+	for _, rule := range rules {
+		d.rules = append(d.rules, &duckingRule{
+			rule:      rule,
+			voices:    SplitTags(rule.VoiceControls),
+			music:     SplitTags(rule.MusicControls),
+			voiceOpen: make(map[string]bool),
+		})
+	}
+	d.mu.Unlock()
+
+	for _, volumes := range restores {
+		d.setVolumes(volumes)
+	}
+}
+
+// States reports every active rule.
+func (d *Ducker) States() []DuckingRuleState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	states := make([]DuckingRuleState, 0, len(d.rules))
+	for _, r := range d.rules {
+		open := []string{}
+		for _, v := range r.voices {
+			if r.voiceOpen[v] {
+				open = append(open, v)
+			}
+		}
+		states = append(states, DuckingRuleState{RuleID: r.rule.ID, Name: r.rule.Name, Ducked: r.ducked, OpenVoices: open})
+	}
+	return states
+}
+
+// OnCommand is registered with Hub.AddCommandListener. It only queues the
+// change for the worker, so the request that made it is not held up.
+func (d *Ducker) OnCommand(cmd CommandExecutedData) {
+	if !strings.HasPrefix(cmd.Command, "controls.") || !strings.HasSuffix(cmd.Command, ".set") {
+		return
+	}
+	controlID := strings.TrimSuffix(strings.TrimPrefix(cmd.Command, "controls."), ".set")
+
+	// The payload is whatever the handler broadcast ({"value": ...})
+	raw, err := json.Marshal(cmd.Payload)
+	if err != nil {
+		return
+	}
+	var payload struct {
+		Value interface{} `json:"value"`
+	}
+	if json.Unmarshal(raw, &payload) != nil {
+		return
+	}
+
+	select {
+	case d.commands <- duckingCommand{controlID: controlID, value: payload.Value}:
+	default:
+		// The next poll still sees mute changes
+		log.Printf("⚠️  Ducking queue full, ignoring change of control %s", controlID)
+	}
+}
+
+func (d *Ducker) worker() {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case cmd := <-d.commands:
+			d.command(cmd)
+		case <-ticker.C:
+			d.poll()
+		case <-d.stopChan:
+			return
+		}
+	}
+}
+
+func (d *Ducker) command(cmd duckingCommand) {
+	var changes []duckingChange
+
+	d.mu.Lock()
+	for _, r := range d.rules {
+		switch value := cmd.value.(type) {
+		case bool:
+			if containsString(r.voices, cmd.controlID) {
+				r.voiceOpen[cmd.controlID] = !value
+				if change, ok := d.evaluate(r); ok {
+					changes = append(changes, change)
+				}
+			}
+		case float64:
+			// An operator moved a ducked fader: leave it where they put it
+			if r.ducked && containsString(r.music, cmd.controlID) {
+				delete(r.originals, cmd.controlID)
+			}
+		}
+	}
+	d.mu.Unlock()
+
+	for _, change := range changes {
+		d.apply(change)
+	}
+}
+
+func (d *Ducker) poll() {
+	d.mu.Lock()
+	var voices []string
+	for _, r := range d.rules {
+		voices = append(voices, r.voices...)
+	}
+	d.mu.Unlock()
+
+	if len(voices) == 0 {
+		return
+	}
+
+	muteReader, _ := d.hwClient.(hardware.MuteReader)
+	muted := make(map[string]bool)
+	for _, id := range voices {
+		if _, seen := muted[id]; seen {
+			continue
+		}
+		if muteReader != nil {
+			if m, err := muteReader.GetControlMute(id); err == nil {
+				muted[id] = m
+			}
+			continue
+		}
+		if value, err := d.hwClient.GetControlValue(id); err == nil {
+			if m, ok := value.Value.(bool); ok {
+				muted[id] = m
+			}
+		}
+	}
+
+	var changes []duckingChange
+	d.mu.Lock()
+	for _, r := range d.rules {
+		for _, v := range r.voices {
+			if m, ok := muted[v]; ok {
+				r.voiceOpen[v] = !m
+			}
+		}
+		if change, ok := d.evaluate(r); ok {
+			changes = append(changes, change)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, change := range changes {
+		d.apply(change)
+	}
+}
+
+// evaluate decides whether r must duck or release. Called with d.mu held;
+// the change is carried out by apply.
+func (d *Ducker) evaluate(r *duckingRule) (duckingChange, bool) {
+	open := false
+	for _, v := range r.voices {
+		if r.voiceOpen[v] {
+			open = true
+			break
+		}
+	}
+
+	switch {
+	case open && (!r.ducked || r.releasing):
+		// Duck, or voice reopened while releasing: (back) down to the ducked level
+		r.generation++
+		r.ducked = true
+		r.releasing = false
+		return duckingChange{rule: r, generation: r.generation, duck: true}, true
+
+	case !open && r.ducked && !r.releasing:
+		r.generation++
+		r.releasing = true
+		return duckingChange{rule: r, generation: r.generation, hold: time.Duration(r.rule.HoldMs) * time.Millisecond}, true
+	}
+	return duckingChange{}, false
+}
+
+// apply reads what a change needs from the device and starts its ramp.
+func (d *Ducker) apply(change duckingChange) {
+	r := change.rule
+
+	if change.duck {
+		d.mu.Lock()
+		needOriginals := r.originals == nil
+		d.mu.Unlock()
+
+		if needOriginals {
+			volumes := d.readVolumes(r.music)
+			d.mu.Lock()
+			if r.generation == change.generation && r.originals == nil {
+				r.originals = volumes
+			}
+			d.mu.Unlock()
+		}
+	}
+
+	var limits map[string][2]float64
+	if change.duck {
+		limits = d.readLimits()
+	}
+
+	d.mu.Lock()
+	if r.generation != change.generation {
+		d.mu.Unlock()
+		return
+	}
+	targets := make(map[string]float64, len(r.originals))
+	for id, v := range r.originals {
+		if change.duck {
+			v -= r.rule.DuckBy
+			// Never below what the control accepts
+			if limit, ok := limits[id]; ok {
+				v = math.Max(limit[0], math.Min(limit[1], v))
+			}
+		}
+		targets[id] = v
+	}
+	d.mu.Unlock()
+
+	go d.ramp(r, change.generation, targets, change.hold)
+}
+
+// ramp fades the music controls to targets after hold, giving up as soon
+// as the rule's generation changes.
+func (d *Ducker) ramp(r *duckingRule, generation int, targets map[string]float64, hold time.Duration) {
+	if hold > 0 {
+		time.Sleep(hold)
+	}
+	if !d.current(r, generation) {
+		return
+	}
+
+	from := d.readVolumes(keys(targets))
+	steps := int(time.Duration(r.rule.RampMs) * time.Millisecond / rampStep)
+	if steps < 1 {
+		steps = 1
+	}
+
+	for step := 1; step <= steps; step++ {
+		if !d.current(r, generation) {
+			return
+		}
+		for id, target := range targets {
+			start, ok := from[id]
+			if !ok {
+				start = target
+			}
+			value := start + (target-start)*float64(step)/float64(steps)
+			if err := d.hwClient.SetControlValue(id, value); err != nil && step == steps {
+				log.Printf("⚠️  Ducking %s: failed to set control %s: %v", r.rule.Name, id, err)
+			}
+		}
+
+		if step < steps {
+			time.Sleep(rampStep)
+		}
+	}
+
+	d.mu.Lock()
+	if r.generation == generation && r.releasing {
+		r.ducked = false
+		r.releasing = false
+		r.originals = nil
+	}
+	d.mu.Unlock()
+}
+
+// current reports whether generation is still the rule's latest change.
+func (d *Ducker) current(r *duckingRule, generation int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return r.generation == generation
+}
+
+// release cancels any ramp of r and returns the volumes to restore, nil if
+// it was not ducked. Called with d.mu held.
+func (d *Ducker) release(r *duckingRule) map[string]float64 {
+	r.generation++
+	if !r.ducked {
+		return nil
+	}
+	originals := r.originals
+	r.ducked = false
+	r.releasing = false
+	r.originals = nil
+	return originals
+}
+
+func (d *Ducker) setVolumes(volumes map[string]float64) {
+	for id, v := range volumes {
+		d.hwClient.SetControlValue(id, v)
+	}
+}
+
+// readLimits returns the min and max of the controls that declare them.
+func (d *Ducker) readLimits() map[string][2]float64 {
+	controls, err := d.hwClient.GetControls()
+	if err != nil {
+		log.Printf("⚠️  Ducking: failed to read control limits: %v", err)
+		return nil
+	}
+	limits := make(map[string][2]float64)
+	for _, c := range controls.Controls {
+		if c.Min != nil && c.Max != nil {
+			limits[strconv.Itoa(c.ID)] = [2]float64{float64(*c.Min), float64(*c.Max)}
+		}
+	}
+	return limits
+}
+
+func (d *Ducker) readVolumes(ids []string) map[string]float64 {
+	volumes := make(map[string]float64, len(ids))
+	for _, id := range ids {
+		if value, err := d.hwClient.GetControlValue(id); err == nil {
+			if v, ok := value.Value.(float64); ok {
+				volumes[id] = v
+			}
+		}
+	}
+	return volumes
+}
+
+func keys(m map[string]float64) []string {
+	list := make([]string, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	return list
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
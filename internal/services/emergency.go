@@ -0,0 +1,243 @@
+package services
+
+import (
+	"av-control/internal/hardware"
+	"av-control/internal/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmergencySnapshotName is the snapshot holding the state captured by the
+// last mute-all. It survives a restart, so it can still be applied by hand.
+const EmergencySnapshotName = "emergency"
+
+// emergencyCaptureWait is how long muting a control waits for its state to
+// be captured. A slower read is dropped, since it could see the muted state.
+const emergencyCaptureWait = 500 * time.Millisecond
+
+var (
+	ErrEmergencyActive = errors.New("emergency mute is active")
+	ErrNoEmergency     = errors.New("no emergency mute is active")
+)
+
+// EmergencyState is the current emergency mute, broadcast on every change.
+type EmergencyState struct {
+	Active    bool           `json:"active"`
+	Reason    string         `json:"reason,omitempty"`
+	StartedBy string         `json:"started_by,omitempty"`
+	StartedAt *time.Time     `json:"started_at,omitempty"`
+	Captured  int            `json:"captured_controls"`
+	Results   []ActionResult `json:"results,omitempty"`
+}
+
+// Emergency silences everything at once and restores it afterwards. While
+// it is active the API is locked for non-admins and the ActionExecutor
+// refuses to run, so rules and hooks cannot unmute anything.
+type Emergency struct {
+	db       *gorm.DB
+	hwClient hardware.HardwareClient
+	actions  *ActionExecutor
+	hub      *Hub
+
+	mu       sync.Mutex
+	state    EmergencyState
+	captured []ControlState
+}
+
+func NewEmergency(db *gorm.DB, hwClient hardware.HardwareClient, actions *ActionExecutor, hub *Hub) *Emergency {
+	em := &Emergency{db: db, hwClient: hwClient, actions: actions, hub: hub}
+	actions.SetGuard(em.Guard)
+	return em
+}
+
+// Active reports whether the emergency mute is on.
+func (em *Emergency) Active() bool {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	return em.state.Active
+}
+
+// State returns a copy of the current state.
+func (em *Emergency) State() EmergencyState {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	return em.state
+}
+
+// Guard is installed on the ActionExecutor.
+func (em *Emergency) Guard() error {
+	if em.Active() {
+		return ErrEmergencyActive
+	}
+	return nil
+}
+
+// MuteAll captures and mutes every control and stops the player, all in
+// parallel, so a slow control does not hold up the others. Calling it again
+// while active mutes again without overwriting the captured state.
+func (em *Emergency) MuteAll(username, reason string) (EmergencyState, error) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	controls, err := em.hwClient.GetControls()
+	if err != nil {
+		// Still try to silence the player
+		em.hwClient.Stop()
+		return em.state, err
+	}
+
+	if !em.state.Active {
+		// Stopping the player does not change the captured controls, so it
+		// does not wait for the capture
+		stopped := make(chan error, 1)
+		go func() { stopped <- em.hwClient.Stop() }()
+
+		captured, results := em.captureAndMute(controls.Controls)
+		em.captured = captured
+		em.saveSnapshot(username)
+
+		now := time.Now()
+		em.state = EmergencyState{
+			Active:    true,
+			Reason:    reason,
+			StartedBy: username,
+			StartedAt: &now,
+			Captured:  len(captured),
+		}
+		em.state.Results = append(results, actionResult(ActionStop, "", <-stopped))
+	} else {
+		results := make(chan ActionResult, 1)
+		go func() { results <- actionResult(ActionStop, "", em.hwClient.Stop()) }()
+		em.state.Results = append(em.muteControls(controls.Controls), <-results)
+	}
+
+	log.Printf("🚨 Emergency mute-all by %s (%s)", username, reason)
+	em.broadcast()
+	return em.state, nil
+}
+
+// Restore writes the captured controls back and lifts the lock. The player
+// stays stopped. The lock is lifted even when some controls fail, since the
+// snapshot can still be applied by hand.
+func (em *Emergency) Restore(username string) (EmergencyState, error) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	if !em.state.Active {
+		return em.state, ErrNoEmergency
+	}
+
+	err := em.actions.RestoreControls(em.captured)
+	em.state = EmergencyState{Results: []ActionResult{actionResult(ActionApplySnapshot, EmergencySnapshotName, err)}}
+	em.captured = nil
+
+	log.Printf("✅ Emergency mute lifted by %s", username)
+	em.broadcast()
+	return em.state, err
+}
+
+// captureAndMute reads the state of every control and mutes the mute
+// controls. Each control is handled on its own, and a mute waits for the
+// control's read at most emergencyCaptureWait.
+func (em *Emergency) captureAndMute(controls []models.Control) ([]ControlState, []ActionResult) {
+	var wg sync.WaitGroup
+	states := make([][]ControlState, len(controls))
+	results := make([]ActionResult, 0, len(controls))
+	var resultsMu sync.Mutex
+
+	for i, c := range controls {
+		id := strconv.Itoa(c.ID)
+		mute := strings.Contains(c.Type, "mute")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			read := make(chan []ControlState, 1)
+			go func() {
+				captured, _ := em.actions.CaptureControls([]string{id})
+				read <- captured
+			}()
+
+			if !mute {
+				states[i] = <-read
+				return
+			}
+
+			select {
+			case states[i] = <-read:
+			case <-time.After(emergencyCaptureWait):
+				log.Printf("⚠️  Emergency: control %s did not answer in time, it will not be restored", id)
+			}
+			result := actionResult(ActionSetControl, id, em.hwClient.SetControlValue(id, true))
+			resultsMu.Lock()
+			results = append(results, result)
+			resultsMu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	var captured []ControlState
+	for _, s := range states {
+		captured = append(captured, s...)
+	}
+	return captured, results
+}
+
+func (em *Emergency) muteControls(controls []models.Control) []ActionResult {
+	var wg sync.WaitGroup
+	results := make([]ActionResult, 0, len(controls))
+	var resultsMu sync.Mutex
+
+	for _, c := range controls {
+		if !strings.Contains(c.Type, "mute") {
+			continue
+		}
+		id := strconv.Itoa(c.ID)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := actionResult(ActionSetControl, id, em.hwClient.SetControlValue(id, true))
+			resultsMu.Lock()
+			results = append(results, result)
+			resultsMu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (em *Emergency) saveSnapshot(username string) {
+	if em.captured == nil {
+		return
+	}
+	data, _ := json.Marshal(em.captured)
+
+	var snapshot models.Snapshot
+	em.db.Where("name = ?", EmergencySnapshotName).Limit(1).Find(&snapshot)
+	snapshot.Name = EmergencySnapshotName
+	snapshot.Controls = string(data)
+	snapshot.CreatedBy = username
+	if err := em.db.Save(&snapshot).Error; err != nil {
+		log.Printf("⚠️  Emergency: failed to save snapshot: %v", err)
+	}
+}
+
+func (em *Emergency) broadcast() {
+	if em.hub != nil {
+		em.hub.BroadcastEmergency(em.state)
+	}
+}
+
+func actionResult(actionType, target string, err error) ActionResult {
+	result := ActionResult{Type: actionType, Target: target, Success: err == nil}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
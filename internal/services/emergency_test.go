@@ -0,0 +1,90 @@
+package services
+
+import (
+	"av-control/internal/hardware"
+	"av-control/internal/models"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// slowControlClient is the mock device with one control that answers reads
+// late. The mock is not safe for concurrent use, so calls are serialized.
+type slowControlClient struct {
+	*hardware.MockHardwareClient
+	slowID string
+	delay  time.Duration
+	mu     sync.Mutex
+}
+
+func (c *slowControlClient) GetControlValue(id string) (*models.ControlValue, error) {
+	if id == c.slowID {
+		time.Sleep(c.delay)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.MockHardwareClient.GetControlValue(id)
+}
+
+func (c *slowControlClient) GetControlMute(id string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.MockHardwareClient.GetControlMute(id)
+}
+
+func (c *slowControlClient) SetControlValue(id string, value interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.MockHardwareClient.SetControlValue(id, value)
+}
+
+func TestEmergencyMuteDoesNotWaitForSlowReads(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Snapshot{}); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &slowControlClient{MockHardwareClient: hardware.NewMockHardwareClient(), slowID: "200000", delay: 3 * emergencyCaptureWait}
+	em := NewEmergency(db, client, NewActionExecutor(db, client, nil), nil)
+
+	start := time.Now()
+	state, err := em.MuteAll("admin", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= client.delay {
+		t.Errorf("MuteAll took %s, want it not to wait for the slow control (%s)", elapsed, client.delay)
+	}
+
+	for _, id := range []string{"100000", "200000"} {
+		if mute, _ := client.GetControlMute(id); !mute {
+			t.Errorf("control %s is not muted", id)
+		}
+	}
+	// The slow control's late read could see the muted state, so it is dropped
+	if state.Captured != 1 {
+		t.Errorf("captured %d controls, want 1", state.Captured)
+	}
+	for _, r := range state.Results {
+		if !r.Success {
+			t.Errorf("%s %s failed: %s", r.Type, r.Target, r.Error)
+		}
+	}
+
+	if _, err := em.Restore("admin"); err != nil {
+		t.Fatal(err)
+	}
+	if mute, _ := client.GetControlMute("100000"); mute {
+		t.Error("control 100000 is still muted after restore")
+	}
+}
@@ -0,0 +1,457 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Signing algorithms accepted for JWT keys
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+	AlgES256 = "ES256"
+)
+
+// LegacyKeyID verifies tokens without a kid header, signed with JWT_SECRET
+// before key sets existed.
+const LegacyKeyID = "legacy"
+
+// Token types in the typ claim. Parse only accepts the expected one, so an
+// access token cannot be used as a refresh token or the other way round.
+const (
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+)
+
+// legacyKeyOverlap is how long JWT_SECRET still verifies tokens without a
+// kid once the keys file is created: the lifetime of a refresh token.
+const legacyKeyOverlap = 60 * 24 * time.Hour
+
+// keysReloadInterval is how often the keys file is checked for a rotation
+// made by the CLI while the server runs.
+const keysReloadInterval = 10 * time.Second
+
+var (
+	ErrUnknownKey      = errors.New("unknown signing key")
+	ErrKeyRetired      = errors.New("signing key is retired")
+	ErrUnsupportedAlg  = errors.New("unsupported signing algorithm")
+	ErrNoSigningKey    = errors.New("no active signing key")
+	ErrInvalidKeyToken = errors.New("invalid token")
+)
+
+// SigningKey is one entry of the keys file. Key holds the HMAC secret or
+// the PKCS8 private key, base64 encoded.
+type SigningKey struct {
+	ID        string     `json:"kid"`
+	Alg       string     `json:"alg"`
+	Key       string     `json:"key"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiresAt *time.Time `json:"retires_at,omitempty"` // no longer accepted after this
+
+	sign   interface{}
+	verify interface{}
+}
+
+// keysFile is the JSON layout of JWT_KEYS_FILE. LegacyRetiresAt ends the
+// JWT_SECRET fallback for tokens without a kid.
+type keysFile struct {
+	Active          string        `json:"active"`
+	Keys            []*SigningKey `json:"keys"`
+	LegacyRetiresAt *time.Time    `json:"legacy_retires_at,omitempty"`
+}
+
+// KeySetConfig selects where the keys come from. Without a File the
+// Secret is the only key, as before key sets existed.
+type KeySetConfig struct {
+	File   string // JWT_KEYS_FILE, created with one Alg key when missing
+	Secret string // JWT_SECRET
+	Alg    string // algorithm of the first generated key
+}
+
+// KeySet signs tokens with the active key and verifies them with any key
+// that is not retired, so a rotation does not log anybody out.
+type KeySet struct {
+	file   string
+	legacy *SigningKey
+
+	mu              sync.RWMutex
+	active          string
+	keys            map[string]*SigningKey
+	legacyRetiresAt *time.Time
+	modTime         time.Time
+	checkedAt       time.Time
+}
+
+func NewKeySet(cfg KeySetConfig) (*KeySet, error) {
+	ks := &KeySet{file: cfg.File, keys: map[string]*SigningKey{}}
+
+	if cfg.Secret != "" {
+		ks.legacy = &SigningKey{ID: LegacyKeyID, Alg: AlgHS256, Key: base64.StdEncoding.EncodeToString([]byte(cfg.Secret))}
+		if err := ks.legacy.decode(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.File == "" {
+		if ks.legacy == nil {
+			return nil, ErrNoSigningKey
+		}
+		ks.keys[LegacyKeyID] = ks.legacy
+		ks.active = LegacyKeyID
+		return ks, nil
+	}
+
+	if _, err := os.Stat(cfg.File); errors.Is(err, os.ErrNotExist) {
+		alg := cfg.Alg
+		if alg == "" {
+			alg = AlgEdDSA
+		}
+		if _, err := RotateKeysFile(cfg.File, alg, 0); err != nil {
+			return nil, err
+		}
+		log.Printf("🔑 Created JWT keys file %s (%s)", cfg.File, alg)
+		if ks.legacy != nil {
+			log.Printf("🔑 JWT_SECRET keeps verifying older tokens for %s", legacyKeyOverlap)
+		}
+	}
+
+	if err := ks.load(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Sign signs claims with the active key, setting the kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	ks.reloadIfChanged()
+
+	ks.mu.RLock()
+	key := ks.keys[ks.active]
+	ks.mu.RUnlock()
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Alg), claims)
+	if key.ID != LegacyKeyID {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.sign)
+}
+
+// Parse verifies a token of type typ and returns its claims. The key is
+// picked by kid and must use the algorithm the token claims, so an HMAC
+// token cannot be verified with a public key. Expiry is required.
+func (ks *KeySet) Parse(tokenString, typ string) (jwt.MapClaims, error) {
+	ks.reloadIfChanged()
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := ks.lookup(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.Alg {
+			return nil, fmt.Errorf("%w: %s token for a %s key", ErrUnsupportedAlg, token.Method.Alg(), key.Alg)
+		}
+		return key.verify, nil
+	}, jwt.WithValidMethods([]string{AlgHS256, AlgEdDSA, AlgES256}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, ErrInvalidKeyToken
+	}
+	if claims["typ"] != typ {
+		return nil, fmt.Errorf("%w: not a %s token", ErrInvalidKeyToken, typ)
+	}
+	return claims, nil
+}
+
+func (ks *KeySet) lookup(kid string) (*SigningKey, error) {
+	ks.mu.RLock()
+	key := ks.keys[kid]
+	legacyRetiresAt := ks.legacyRetiresAt
+	ks.mu.RUnlock()
+
+	if kid == "" {
+		if ks.legacy == nil {
+			return nil, fmt.Errorf("%w: token has no kid", ErrUnknownKey)
+		}
+		if legacyRetiresAt != nil && time.Now().After(*legacyRetiresAt) {
+			return nil, fmt.Errorf("%w: %s", ErrKeyRetired, LegacyKeyID)
+		}
+		return ks.legacy, nil
+	}
+
+	if key == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+	if key.RetiresAt != nil && time.Now().After(*key.RetiresAt) {
+		return nil, fmt.Errorf("%w: %s", ErrKeyRetired, kid)
+	}
+	return key, nil
+}
+
+// reloadIfChanged picks up a rotation done by the CLI, checking the file at
+// most every keysReloadInterval.
+func (ks *KeySet) reloadIfChanged() {
+	if ks.file == "" {
+		return
+	}
+
+	ks.mu.Lock()
+	if time.Since(ks.checkedAt) < keysReloadInterval {
+		ks.mu.Unlock()
+		return
+	}
+	ks.checkedAt = time.Now()
+	modTime := ks.modTime
+	ks.mu.Unlock()
+
+	info, err := os.Stat(ks.file)
+	if err != nil || info.ModTime().Equal(modTime) {
+		return
+	}
+	if err := ks.load(); err != nil {
+		log.Printf("⚠️  Failed to reload JWT keys, keeping the current ones: %v", err)
+		return
+	}
+	log.Printf("🔑 Reloaded JWT keys from %s", ks.file)
+}
+
+func (ks *KeySet) load() error {
+	info, err := os.Stat(ks.file)
+	if err != nil {
+		return err
+	}
+	kf, err := readKeysFile(ks.file)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*SigningKey, len(kf.Keys))
+	for _, k := range kf.Keys {
+		if err := k.decode(); err != nil {
+			return fmt.Errorf("key %s: %w", k.ID, err)
+		}
+		keys[k.ID] = k
+	}
+	if keys[kf.Active] == nil {
+		return fmt.Errorf("%w: active key %q is not in %s", ErrNoSigningKey, kf.Active, ks.file)
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.active = kf.Active
+	ks.legacyRetiresAt = kf.LegacyRetiresAt
+	ks.modTime = info.ModTime()
+	ks.checkedAt = time.Now()
+	ks.mu.Unlock()
+	return nil
+}
+
+// decode parses Key into the signing and verification keys.
+func (k *SigningKey) decode() error {
+	raw, err := base64.StdEncoding.DecodeString(k.Key)
+	if err != nil {
+		return err
+	}
+
+	switch k.Alg {
+	case AlgHS256:
+		k.sign, k.verify = raw, raw
+		return nil
+	case AlgEdDSA, AlgES256:
+		private, err := x509.ParsePKCS8PrivateKey(raw)
+		if err != nil {
+			return err
+		}
+		switch p := private.(type) {
+		case ed25519.PrivateKey:
+			if k.Alg != AlgEdDSA {
+				return fmt.Errorf("%w: Ed25519 key marked %s", ErrUnsupportedAlg, k.Alg)
+			}
+			k.sign, k.verify = p, p.Public()
+		case *ecdsa.PrivateKey:
+			if k.Alg != AlgES256 || p.Curve != elliptic.P256() {
+				return fmt.Errorf("%w: ECDSA key marked %s", ErrUnsupportedAlg, k.Alg)
+			}
+			k.sign, k.verify = p, &p.PublicKey
+		default:
+			return fmt.Errorf("%w: %T", ErrUnsupportedAlg, private)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedAlg, k.Alg)
+	}
+}
+
+// generateKey creates a new key for alg.
+func generateKey(alg string) (*SigningKey, error) {
+	var raw []byte
+	switch alg {
+	case AlgHS256:
+		raw = make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+	case AlgEdDSA, AlgES256:
+		var private crypto.PrivateKey
+		var err error
+		if alg == AlgEdDSA {
+			_, private, err = ed25519.GenerateKey(rand.Reader)
+		} else {
+			private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if raw, err = x509.MarshalPKCS8PrivateKey(private); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q (use %s, %s or %s)", ErrUnsupportedAlg, alg, AlgHS256, AlgEdDSA, AlgES256)
+	}
+
+	return &SigningKey{
+		ID:        uuid.New().String()[:8],
+		Alg:       alg,
+		Key:       base64.StdEncoding.EncodeToString(raw),
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// RotateKeysFile adds a new active key to the keys file, creating it when
+// missing. The previous active key keeps verifying tokens for overlap, so
+// sessions survive the rotation; keys already past retirement are dropped.
+// The JWT_SECRET fallback retires with the previous key at the latest; a
+// new file gives it legacyKeyOverlap.
+func RotateKeysFile(path, alg string, overlap time.Duration) (*SigningKey, error) {
+	now := time.Now().UTC()
+	legacyRetires := now.Add(legacyKeyOverlap)
+	kf := &keysFile{LegacyRetiresAt: &legacyRetires}
+	if _, err := os.Stat(path); err == nil {
+		if kf, err = readKeysFile(path); err != nil {
+			return nil, err
+		}
+		kf.retireLegacy(now.Add(overlap))
+	}
+
+	key, err := generateKey(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make([]*SigningKey, 0, len(kf.Keys)+1)
+	for _, k := range kf.Keys {
+		if k.RetiresAt != nil && now.After(*k.RetiresAt) {
+			continue
+		}
+		if k.ID == kf.Active {
+			retires := now.Add(overlap)
+			k.RetiresAt = &retires
+		}
+		kept = append(kept, k)
+	}
+	kf.Keys = append(kept, key)
+	kf.Active = key.ID
+
+	if err := writeKeysFile(path, kf); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// RetireLegacyKeyFile stops the JWT_SECRET fallback at the given time, or
+// keeps an earlier retirement already set.
+func RetireLegacyKeyFile(path string, at time.Time) (time.Time, error) {
+	kf, err := readKeysFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	kf.retireLegacy(at.UTC())
+	if err := writeKeysFile(path, kf); err != nil {
+		return time.Time{}, err
+	}
+	return *kf.LegacyRetiresAt, nil
+}
+
+func (kf *keysFile) retireLegacy(at time.Time) {
+	if kf.LegacyRetiresAt == nil || at.Before(*kf.LegacyRetiresAt) {
+		kf.LegacyRetiresAt = &at
+	}
+}
+
+// ListKeysFile returns the active kid and the keys of the file without
+// their secret material. The JWT_SECRET fallback is listed as LegacyKeyID.
+func ListKeysFile(path string) (string, []SigningKey, error) {
+	kf, err := readKeysFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	keys := make([]SigningKey, 0, len(kf.Keys)+1)
+	if kf.LegacyRetiresAt != nil {
+		keys = append(keys, SigningKey{ID: LegacyKeyID, Alg: AlgHS256, RetiresAt: kf.LegacyRetiresAt})
+	}
+	for _, k := range kf.Keys {
+		keys = append(keys, SigningKey{ID: k.ID, Alg: k.Alg, CreatedAt: k.CreatedAt, RetiresAt: k.RetiresAt})
+	}
+	return kf.Active, keys, nil
+}
+
+func readKeysFile(path string) (*keysFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var kf keysFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("invalid keys file %s: %w", path, err)
+	}
+	return &kf, nil
+}
+
+// writeKeysFile replaces the file atomically, readable by the owner only.
+func writeKeysFile(path string, kf *keysFile) error {
+	data, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".jwt-keys-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
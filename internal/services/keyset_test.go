@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestKeySetTokenType(t *testing.T) {
+	ks, err := NewKeySet(KeySetConfig{File: filepath.Join(t.TempDir(), "keys.json"), Alg: AlgEdDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(claims jwt.MapClaims) string {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token, err := ks.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	access := sign(jwt.MapClaims{"typ": TokenAccess, "user_id": "u1"})
+	refresh := sign(jwt.MapClaims{"typ": TokenRefresh, "user_id": "u1"})
+	untyped := sign(jwt.MapClaims{"user_id": "u1"})
+
+	tests := []struct {
+		name  string
+		token string
+		typ   string
+		ok    bool
+	}{
+		{"access as access", access, TokenAccess, true},
+		{"refresh as refresh", refresh, TokenRefresh, true},
+		{"refresh as access", refresh, TokenAccess, false},
+		{"access as refresh", access, TokenRefresh, false},
+		{"no typ as access", untyped, TokenAccess, false},
+		{"no typ as refresh", untyped, TokenRefresh, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ks.Parse(tt.token, tt.typ)
+			if (err == nil) != tt.ok {
+				t.Errorf("Parse(%s) = %v, want ok %v", tt.typ, err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestKeySetLegacyRetirement(t *testing.T) {
+	const secret = "legacy-secret-legacy-secret-1234"
+	legacy, err := NewKeySet(KeySetConfig{Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := legacy.Sign(jwt.MapClaims{"typ": TokenAccess, "exp": time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Moving to a keys file keeps the old tokens working
+	file := filepath.Join(t.TempDir(), "keys.json")
+	ks, err := NewKeySet(KeySetConfig{File: file, Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Parse(token, TokenAccess); err != nil {
+		t.Fatalf("token without kid rejected after creating the keys file: %v", err)
+	}
+
+	// A rotation retires the fallback with the previous key
+	if _, err := RotateKeysFile(file, AlgEdDSA, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	_, keys, err := ListKeysFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys[0].ID != LegacyKeyID || keys[0].RetiresAt == nil || keys[0].RetiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("legacy key after rotation = %+v, want it retiring within the overlap", keys[0])
+	}
+
+	if _, err := RetireLegacyKeyFile(file, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	ks, err = NewKeySet(KeySetConfig{File: file, Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Parse(token, TokenAccess); !errors.Is(err, ErrKeyRetired) {
+		t.Errorf("Parse after retirement = %v, want ErrKeyRetired", err)
+	}
+}
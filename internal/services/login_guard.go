@@ -0,0 +1,249 @@
+package services
+
+import (
+	"av-control/internal/models"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoginGuardConfig tunes the brute-force protection of the login.
+type LoginGuardConfig struct {
+	DelayAfter      int           // failures before attempts are slowed down
+	BaseDelay       time.Duration // first delay, doubled on every further failure
+	MaxDelay        time.Duration
+	LockoutAfter    int           // consecutive failures that lock an account
+	LockoutDuration time.Duration // also how long an IP stays blocked
+	IPLockoutAfter  int           // failures from one IP, any username, that block it
+}
+
+// LoginBlock is an IP that failed too often.
+type LoginBlock struct {
+	IP           string     `json:"ip"`
+	Failures     int        `json:"failures"`
+	LastFailure  time.Time  `json:"last_failure"`
+	BlockedUntil *time.Time `json:"blocked_until,omitempty"`
+}
+
+type ipAttempts struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// LoginGuard slows down repeated login failures per IP and per account and
+// locks them out for a while. Accounts are tracked on the user record so a
+// restart does not reset them; IPs are tracked in memory.
+type LoginGuard struct {
+	db    *gorm.DB
+	audit *AuditService
+	cfg   LoginGuardConfig
+
+	mu  sync.Mutex
+	ips map[string]*ipAttempts
+}
+
+func NewLoginGuard(db *gorm.DB, audit *AuditService, cfg LoginGuardConfig) *LoginGuard {
+	return &LoginGuard{db: db, audit: audit, cfg: cfg, ips: map[string]*ipAttempts{}}
+}
+
+// CheckIP returns how long ip must wait before trying again.
+func (g *LoginGuard) CheckIP(ip string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	a := g.ips[ip]
+	if a == nil {
+		return 0
+	}
+	now := time.Now()
+	if now.Before(a.blockedUntil) {
+		return a.blockedUntil.Sub(now)
+	}
+	return g.wait(a.failures, a.lastFailure, now)
+}
+
+// CheckUser returns how long the account must wait before trying again and
+// whether that is because it is locked.
+func (g *LoginGuard) CheckUser(user *models.User) (time.Duration, bool) {
+	now := time.Now()
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return user.LockedUntil.Sub(now), true
+	}
+	if user.LastFailedLogin == nil {
+		return 0, false
+	}
+	return g.wait(user.FailedLogins, *user.LastFailedLogin, now), false
+}
+
+// Failure records a failed attempt. user is nil when the username does not
+// exist; the attempt still counts against the IP and is audited.
+func (g *LoginGuard) Failure(ip, username string, user *models.User) {
+	now := time.Now()
+	entry := UserChangeEntry{
+		Action:         UserActionLoginFailed,
+		TargetUsername: username,
+		ClientIP:       ip,
+	}
+
+	locked := false
+	if user != nil {
+		entry.TargetUserID = user.ID
+		entry.TargetUsername = user.Username
+
+		// Count in the database so concurrent failures are not lost; only the
+		// request that reaches the threshold starts the lockout
+		g.db.Model(user).Updates(map[string]interface{}{
+			"failed_logins":     gorm.Expr("failed_logins + 1"),
+			"last_failed_login": now,
+		})
+		g.db.Model(&models.User{}).Where("id = ?", user.ID).Select("failed_logins").Scan(&user.FailedLogins)
+		user.LastFailedLogin = &now
+
+		if g.cfg.LockoutAfter > 0 && user.FailedLogins >= g.cfg.LockoutAfter {
+			// After the lockout a fresh round of attempts starts
+			until := now.Add(g.cfg.LockoutDuration)
+			result := g.db.Model(&models.User{}).
+				Where("id = ? AND failed_logins >= ?", user.ID, g.cfg.LockoutAfter).
+				Updates(map[string]interface{}{"locked_until": until, "failed_logins": 0})
+			if result.Error == nil && result.RowsAffected > 0 {
+				user.LockedUntil = &until
+				user.FailedLogins = 0
+				locked = true
+			}
+		}
+	}
+	g.audit.LogUserChange(entry)
+
+	if locked {
+		log.Printf("🔒 Account %s locked until %s after %d failed logins", user.Username, user.LockedUntil.Format("15:04"), g.cfg.LockoutAfter)
+		g.audit.LogUserChange(UserChangeEntry{
+			Action:         UserActionLockout,
+			TargetUserID:   user.ID,
+			TargetUsername: user.Username,
+			ClientIP:       ip,
+			Changes:        map[string]FieldChange{"locked_until": {After: user.LockedUntil}},
+		})
+	}
+
+	if until, blocked := g.failIP(ip, now); blocked {
+		log.Printf("🔒 IP %s blocked until %s after repeated failed logins", ip, until.Format("15:04"))
+		g.audit.LogUserChange(UserChangeEntry{
+			Action:         UserActionIPLockout,
+			TargetUsername: username,
+			ClientIP:       ip,
+			Changes:        map[string]FieldChange{"blocked_until": {After: until}},
+		})
+	}
+}
+
+// Success clears the account's failures. The IP keeps its count, so a valid
+// account cannot be used to reset it between guesses.
+func (g *LoginGuard) Success(user *models.User) {
+	if user.FailedLogins == 0 && user.LockedUntil == nil {
+		return
+	}
+	g.db.Model(user).Updates(map[string]interface{}{
+		"failed_logins":     0,
+		"last_failed_login": nil,
+		"locked_until":      nil,
+	})
+}
+
+// UnlockUser clears the account's failures and lockout.
+func (g *LoginGuard) UnlockUser(user *models.User) error {
+	return g.db.Model(user).Updates(map[string]interface{}{
+		"failed_logins":     0,
+		"last_failed_login": nil,
+		"locked_until":      nil,
+	}).Error
+}
+
+// UnlockIP forgets the failures of ip. It reports whether ip was tracked.
+func (g *LoginGuard) UnlockIP(ip string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.ips[ip]
+	delete(g.ips, ip)
+	return ok
+}
+
+// Blocks lists the IPs with recent failures, blocked ones first.
+func (g *LoginGuard) Blocks() []LoginBlock {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	g.prune(now)
+	blocks := make([]LoginBlock, 0, len(g.ips))
+	for ip, a := range g.ips {
+		block := LoginBlock{IP: ip, Failures: a.failures, LastFailure: a.lastFailure}
+		if now.Before(a.blockedUntil) {
+			until := a.blockedUntil
+			block.BlockedUntil = &until
+		}
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		if (blocks[i].BlockedUntil != nil) != (blocks[j].BlockedUntil != nil) {
+			return blocks[i].BlockedUntil != nil
+		}
+		return blocks[i].LastFailure.After(blocks[j].LastFailure)
+	})
+	return blocks
+}
+
+// failIP counts a failure for ip and reports whether it just got blocked.
+func (g *LoginGuard) failIP(ip string, now time.Time) (time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.prune(now)
+	a := g.ips[ip]
+	if a == nil {
+		a = &ipAttempts{}
+		g.ips[ip] = a
+	}
+	a.failures++
+	a.lastFailure = now
+
+	if g.cfg.IPLockoutAfter > 0 && a.failures >= g.cfg.IPLockoutAfter && !now.Before(a.blockedUntil) {
+		a.blockedUntil = now.Add(g.cfg.LockoutDuration)
+		a.failures = 0
+		return a.blockedUntil, true
+	}
+	return time.Time{}, false
+}
+
+// prune forgets IPs that have been quiet for a lockout duration.
+func (g *LoginGuard) prune(now time.Time) {
+	for ip, a := range g.ips {
+		if now.Sub(a.lastFailure) > g.cfg.LockoutDuration && !now.Before(a.blockedUntil) {
+			delete(g.ips, ip)
+		}
+	}
+}
+
+// wait is the delay required after failures consecutive failures, the last
+// one at last.
+func (g *LoginGuard) wait(failures int, last, now time.Time) time.Duration {
+	if failures < g.cfg.DelayAfter || g.cfg.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := g.cfg.BaseDelay
+	for i := g.cfg.DelayAfter; i < failures && delay < g.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if g.cfg.MaxDelay > 0 && delay > g.cfg.MaxDelay {
+		delay = g.cfg.MaxDelay
+	}
+
+	if remaining := last.Add(delay).Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+// defaultBannedPasswords are refused whatever the configured list says.
+var defaultBannedPasswords = []string{
+	"admin123", "password", "password1", "12345678", "123456789", "1234567890",
+	"qwerty123", "qwertyuiop", "iloveyou", "letmein", "welcome1", "changeme",
+	"parrocchia", "chiesa123", "oratorio", "amen1234",
+}
+
+// PasswordConfig is the password policy and the hashing setup.
+type PasswordConfig struct {
+	MinLength  int
+	MinClasses int    // of lowercase, uppercase, digits and symbols
+	BannedFile string // one password per line, added to the built-in list
+
+	Hash          string // bcrypt or argon2id
+	BcryptCost    int
+	Argon2Memory  uint32 // KiB
+	Argon2Time    uint32
+	Argon2Threads uint8
+}
+
+// Passwords checks new passwords against the policy and hashes them. Hashes
+// made with other settings keep verifying and are upgraded at login.
+type Passwords struct {
+	cfg    PasswordConfig
+	banned map[string]bool
+}
+
+func NewPasswords(cfg PasswordConfig) (*Passwords, error) {
+	if cfg.Hash == "" {
+		cfg.Hash = HashBcrypt
+	}
+	if cfg.Hash != HashBcrypt && cfg.Hash != HashArgon2id {
+		return nil, fmt.Errorf("unknown password hash %q (use %s or %s)", cfg.Hash, HashBcrypt, HashArgon2id)
+	}
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if cfg.Argon2Memory == 0 || cfg.Argon2Time == 0 || cfg.Argon2Threads == 0 {
+		return nil, fmt.Errorf("argon2 memory, time and threads must be positive")
+	}
+
+	p := &Passwords{cfg: cfg, banned: map[string]bool{}}
+	for _, b := range defaultBannedPasswords {
+		p.banned[b] = true
+	}
+	if cfg.BannedFile != "" {
+		f, err := os.Open(cfg.BannedFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if line := strings.ToLower(strings.TrimSpace(scanner.Text())); line != "" && !strings.HasPrefix(line, "#") {
+				p.banned[line] = true
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Check validates a new password of username against the policy. All the
+// problems are reported at once.
+func (p *Passwords) Check(password, username string) error {
+	var problems []string
+
+	if len([]rune(password)) < p.cfg.MinLength {
+		problems = append(problems, fmt.Sprintf("at least %d characters", p.cfg.MinLength))
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, has := range []bool{lower, upper, digit, symbol} {
+		if has {
+			classes++
+		}
+	}
+	if classes < p.cfg.MinClasses {
+		problems = append(problems, fmt.Sprintf("at least %d of lowercase, uppercase, digits and symbols", p.cfg.MinClasses))
+	}
+
+	lowered := strings.ToLower(password)
+	if p.banned[lowered] {
+		problems = append(problems, "not a common password")
+	}
+	if name := strings.ToLower(strings.TrimSpace(username)); len(name) >= 3 && strings.Contains(lowered, name) {
+		problems = append(problems, "must not contain the username")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrWeakPassword, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// Hash hashes password with the configured algorithm.
+func (p *Passwords) Hash(password string) (string, error) {
+	if p.cfg.Hash == HashBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), p.cfg.BcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.cfg.Argon2Time, p.cfg.Argon2Memory, p.cfg.Argon2Threads, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+		p.cfg.Argon2Memory, p.cfg.Argon2Time, p.cfg.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether password matches hash, and whether the hash should
+// be replaced because it was made with other settings.
+func (p *Passwords) Verify(hash, password string) (ok bool, rehash bool) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, salt, key, err := parseArgon2(hash)
+		if err != nil {
+			return false, false
+		}
+		computed := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false
+		}
+		return true, p.NeedsRehash(hash)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false, false
+	}
+	return true, p.NeedsRehash(hash)
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or other
+// settings than the configured ones.
+func (p *Passwords) NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, _, _, err := parseArgon2(hash)
+		return err != nil || p.cfg.Hash != HashArgon2id || params.memory != p.cfg.Argon2Memory ||
+			params.time != p.cfg.Argon2Time || params.threads != p.cfg.Argon2Threads
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || p.cfg.Hash != HashBcrypt || cost != p.cfg.BcryptCost
+}
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// parseArgon2 splits a PHC string $argon2id$v=..$m=..,t=..,p=..$salt$key.
+func parseArgon2(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	var version int
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, errors.New("malformed argon2id hash")
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, err
+	}
+	if params.memory == 0 || params.time == 0 || params.threads == 0 {
+		return params, nil, nil, errors.New("invalid argon2 parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	if len(key) == 0 {
+		return params, nil, nil, errors.New("empty argon2 key")
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cheap settings so the tests stay fast
+var (
+	testBcrypt = PasswordConfig{Hash: HashBcrypt, BcryptCost: bcrypt.MinCost, Argon2Memory: 64, Argon2Time: 1, Argon2Threads: 1}
+	testArgon2 = PasswordConfig{Hash: HashArgon2id, BcryptCost: bcrypt.MinCost, Argon2Memory: 64, Argon2Time: 1, Argon2Threads: 1}
+)
+
+func newTestPasswords(t *testing.T, cfg PasswordConfig) *Passwords {
+	t.Helper()
+	p, err := NewPasswords(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPasswordsCheck(t *testing.T) {
+	banned := filepath.Join(t.TempDir(), "banned.txt")
+	if err := os.WriteFile(banned, []byte("# parish specific\nSanGiorgio1\n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testBcrypt
+	cfg.MinLength = 8
+	cfg.MinClasses = 2
+	cfg.BannedFile = banned
+	p := newTestPasswords(t, cfg)
+
+	tests := []struct {
+		name     string
+		password string
+		username string
+		problems []string // empty = accepted
+	}{
+		{"valid", "Organo-2026", "mario", nil},
+		{"two classes are enough", "organo2026", "mario", nil},
+		{"unicode letters", "Àèìòù-2026", "mario", nil},
+		{"too short", "Ab1!", "mario", []string{"at least 8 characters"}},
+		{"one class", "organosuona", "mario", []string{"at least 2 of"}},
+		{"built-in banned list", "admin123", "mario", []string{"not a common password"}},
+		{"banned list is case insensitive", "PASSWORD1", "mario", []string{"not a common password"}},
+		{"banned file", "sangiorgio1", "mario", []string{"not a common password"}},
+		{"contains the username", "Mario-2026!", "mario", []string{"must not contain the username"}},
+		{"short usernames are not checked", "Al-2026-organo", "al", nil},
+		{"every problem at once", "mario", "mario", []string{"at least 8 characters", "at least 2 of", "must not contain the username"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Check(tt.password, tt.username)
+			if len(tt.problems) == 0 {
+				if err != nil {
+					t.Errorf("Check(%q) = %v, want nil", tt.password, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrWeakPassword) {
+				t.Fatalf("Check(%q) = %v, want ErrWeakPassword", tt.password, err)
+			}
+			for _, problem := range tt.problems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("Check(%q) = %q, want it to mention %q", tt.password, err, problem)
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordsVerify(t *testing.T) {
+	for _, cfg := range []PasswordConfig{testBcrypt, testArgon2} {
+		t.Run(cfg.Hash, func(t *testing.T) {
+			p := newTestPasswords(t, cfg)
+			hash, err := p.Hash("Organo-2026")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ok, rehash := p.Verify(hash, "Organo-2026"); !ok || rehash {
+				t.Errorf("Verify(right password) = %v, %v, want true, false", ok, rehash)
+			}
+			if ok, _ := p.Verify(hash, "organo-2026"); ok {
+				t.Error("Verify(wrong password) = true")
+			}
+			if ok, _ := p.Verify(hash, ""); ok {
+				t.Error("Verify(empty password) = true")
+			}
+		})
+	}
+
+	p := newTestPasswords(t, testArgon2)
+	malformed := []string{
+		"",
+		"plaintext",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$",
+		"$argon2id$v=19$m=64,t=1,p=1$!!$a2V5",
+	}
+	for _, hash := range malformed {
+		if ok, _ := p.Verify(hash, ""); ok {
+			t.Errorf("Verify(%q) = true for a malformed hash", hash)
+		}
+	}
+}
+
+func TestPasswordsNeedsRehash(t *testing.T) {
+	hashWith := func(cfg PasswordConfig) string {
+		hash, err := newTestPasswords(t, cfg).Hash("Organo-2026")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	bcryptCost5 := testBcrypt
+	bcryptCost5.BcryptCost = bcrypt.MinCost + 1
+	argon2Time2 := testArgon2
+	argon2Time2.Argon2Time = 2
+	argon2Memory128 := testArgon2
+	argon2Memory128.Argon2Memory = 128
+
+	tests := []struct {
+		name    string
+		current PasswordConfig
+		hash    string
+		want    bool
+	}{
+		{"bcrypt, same cost", testBcrypt, hashWith(testBcrypt), false},
+		{"bcrypt, other cost", testBcrypt, hashWith(bcryptCost5), true},
+		{"bcrypt to argon2id", testArgon2, hashWith(testBcrypt), true},
+		{"argon2id, same settings", testArgon2, hashWith(testArgon2), false},
+		{"argon2id, other time", testArgon2, hashWith(argon2Time2), true},
+		{"argon2id, other memory", testArgon2, hashWith(argon2Memory128), true},
+		{"argon2id to bcrypt", testBcrypt, hashWith(testArgon2), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPasswords(t, tt.current)
+			if got := p.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash(%q) = %v, want %v", tt.hash, got, tt.want)
+			}
+			// Verify reports the same for the right password
+			if ok, rehash := p.Verify(tt.hash, "Organo-2026"); !ok || rehash != tt.want {
+				t.Errorf("Verify = %v, %v, want true, %v", ok, rehash, tt.want)
+			}
+		})
+	}
+}
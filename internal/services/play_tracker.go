@@ -0,0 +1,143 @@
+package services
+
+import (
+	"av-control/internal/models"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// restartThreshold: a playing track whose position jumps back to within
+// this many seconds of the start is counted as a new play (repeat mode).
+const restartThreshold = 5
+
+type activePlay struct {
+	title       string
+	startedAt   time.Time
+	lastSeen    time.Time
+	lastPos     int
+	trackLength int
+	played      time.Duration
+	serviceName string
+}
+
+// PlayTracker turns polled player status transitions into models.PlayLog
+// rows: a play starts when a track is seen playing and ends when the title
+// changes, the track restarts or the player stops. Plays shorter than
+// minPlayed (skipped tracks) are not logged.
+type PlayTracker struct {
+	db        *gorm.DB
+	schedule  *Schedule
+	minPlayed time.Duration
+	maxGap    time.Duration
+
+	mu      sync.Mutex
+	current *activePlay
+}
+
+// NewPlayTracker creates a tracker for a poller running every pollInterval.
+func NewPlayTracker(db *gorm.DB, schedule *Schedule, pollInterval, minPlayed time.Duration) *PlayTracker {
+	return &PlayTracker{
+		db:        db,
+		schedule:  schedule,
+		minPlayed: minPlayed,
+		maxGap:    2 * pollInterval,
+	}
+}
+
+// OnStatus implements StatusObserver.
+func (pt *PlayTracker) OnStatus(status *models.SystemStatus) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	now := time.Now()
+	player := status.Player
+	cur := pt.current
+
+	if !status.Connected || player.State == "stopped" || player.State == "" || player.SongTitle == "" {
+		pt.closeLocked(now)
+		return
+	}
+
+	if cur != nil && (cur.title != player.SongTitle ||
+		(player.State == "playing" && player.CurrentTime < cur.lastPos && player.CurrentTime <= restartThreshold)) {
+		pt.closeLocked(now)
+		cur = nil
+	}
+
+	if player.State != "playing" {
+		// Paused: keep the play open, resuming continues it
+		if cur != nil {
+			cur.lastSeen = now
+			cur.lastPos = player.CurrentTime
+		}
+		return
+	}
+
+	if cur == nil {
+		cur = &activePlay{
+			title:       player.SongTitle,
+			startedAt:   now.Add(-time.Duration(player.CurrentTime) * time.Second),
+			lastSeen:    now,
+			lastPos:     player.CurrentTime,
+			trackLength: player.TotalTime,
+			played:      time.Duration(player.CurrentTime) * time.Second,
+		}
+		if pt.schedule != nil {
+			if svc := pt.schedule.Current(now); svc != nil {
+				cur.serviceName = svc.Name
+			}
+		}
+		pt.current = cur
+		return
+	}
+
+	// Count wall-clock time between polls, ignoring gaps where the server
+	// could not observe the player
+	if gap := now.Sub(cur.lastSeen); gap <= pt.maxGap {
+		cur.played += gap
+	}
+	cur.lastSeen = now
+	cur.lastPos = player.CurrentTime
+	if player.TotalTime > 0 {
+		cur.trackLength = player.TotalTime
+	}
+}
+
+// Stop logs the play in progress (called on shutdown).
+func (pt *PlayTracker) Stop() {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.closeLocked(time.Now())
+}
+
+func (pt *PlayTracker) closeLocked(now time.Time) {
+	cur := pt.current
+	pt.current = nil
+	if cur == nil {
+		return
+	}
+
+	played := cur.played
+	if cur.trackLength > 0 && played > time.Duration(cur.trackLength)*time.Second {
+		played = time.Duration(cur.trackLength) * time.Second
+	}
+	if played < pt.minPlayed {
+		return
+	}
+
+	entry := models.PlayLog{
+		SongTitle:     cur.title,
+		StartedAt:     cur.startedAt,
+		EndedAt:       cur.lastSeen,
+		SecondsPlayed: int(played.Seconds()),
+		TrackLength:   cur.trackLength,
+		ServiceName:   cur.serviceName,
+	}
+	if err := pt.db.Create(&entry).Error; err != nil {
+		log.Printf("❌ Failed to log play of %s: %v", cur.title, err)
+	}
+}
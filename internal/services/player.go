@@ -0,0 +1,60 @@
+package services
+
+import (
+	"av-control/internal/hardware"
+	"av-control/internal/models"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidSeekPosition = errors.New("position is beyond the end of the track")
+
+// SeekPlayer moves the current track to position (seconds). When the
+// device cannot seek, a seek to the start is emulated by reselecting the
+// current track; other positions are reported as hardware.ErrNotSupported.
+func SeekPlayer(hwClient hardware.HardwareClient, position int) (emulated bool, err error) {
+	caps, err := hwClient.GetPlayerCapabilities()
+	if err != nil {
+		return false, err
+	}
+
+	status, err := hwClient.GetPlayerStatus()
+	if err != nil {
+		return false, err
+	}
+	if status.TotalTime > 0 && position > status.TotalTime {
+		return false, fmt.Errorf("%w (%ds)", ErrInvalidSeekPosition, status.TotalTime)
+	}
+
+	if caps.Seek {
+		err = hwClient.Seek(position)
+		if !errors.Is(err, hardware.ErrNotSupported) {
+			return false, err
+		}
+	}
+	if position != 0 {
+		return false, hardware.ErrNotSupported
+	}
+	return true, restartTrack(hwClient, status)
+}
+
+// restartTrack plays the current track from the start by selecting it again.
+func restartTrack(hwClient hardware.HardwareClient, status *models.PlayerStatus) error {
+	songs, err := hwClient.GetSongs()
+	if err != nil {
+		return err
+	}
+	for _, song := range songs.Songs {
+		if song.Name != status.SongTitle {
+			continue
+		}
+		if err := hwClient.SelectSong(song.ID); err != nil {
+			return err
+		}
+		if status.State == "playing" {
+			return hwClient.Play()
+		}
+		return nil
+	}
+	return errors.New("current track not found in the song list")
+}
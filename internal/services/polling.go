@@ -2,15 +2,23 @@ package services
 
 import (
 	"av-control/internal/hardware"
+	"av-control/internal/models"
 	"log"
 	"time"
 )
 
+// StatusObserver receives every successfully polled system status.
+// Observers are called from the polling goroutine and must not block.
+type StatusObserver interface {
+	OnStatus(status *models.SystemStatus)
+}
+
 type StatusPoller struct {
-	hwClient hardware.HardwareClient
-	hub      *Hub
-	interval time.Duration
-	stopChan chan struct{}
+	hwClient  hardware.HardwareClient
+	hub       *Hub
+	interval  time.Duration
+	stopChan  chan struct{}
+	observers []StatusObserver
 }
 
 func NewStatusPoller(hwClient hardware.HardwareClient, hub *Hub, interval time.Duration) *StatusPoller {
@@ -22,6 +30,11 @@ func NewStatusPoller(hwClient hardware.HardwareClient, hub *Hub, interval time.D
 	}
 }
 
+// AddObserver registers an observer. Must be called before Start.
+func (p *StatusPoller) AddObserver(o StatusObserver) {
+	p.observers = append(p.observers, o)
+}
+
 func (p *StatusPoller) Start() {
 	go p.pollLoop()
 	log.Println("📊 Status polling started")
@@ -43,6 +56,10 @@ func (p *StatusPoller) pollLoop() {
 			// Broadcast status update
 			p.hub.BroadcastStatusUpdate(status)
 
+			for _, o := range p.observers {
+				o.OnStatus(status)
+			}
+
 		case <-p.stopChan:
 			log.Println("✅ Status polling stopped")
 			return
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"av-control/internal/hardware"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Guard rules that can be enabled for preset loads
+const (
+	GuardRecorderActive = "recorder_active"
+	GuardPlayerPlaying  = "player_playing"
+	GuardServiceSoon    = "service_soon"
+)
+
+const confirmationTTL = 2 * time.Minute
+
+// GuardReason explains why a preset load needs confirmation.
+type GuardReason struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PresetGuardConfig selects the rules evaluated before loading a preset.
+type PresetGuardConfig struct {
+	Rules         []string      // subset of the Guard* constants
+	ServiceWindow time.Duration // how far ahead service_soon looks
+}
+
+type pendingConfirmation struct {
+	presetID  string
+	userID    string
+	expiresAt time.Time
+}
+
+// PresetGuard protects live services from an accidental preset load: when a
+// rule matches, the load is refused until the client repeats it with the
+// one-time confirmation token issued for that user and preset.
+type PresetGuard struct {
+	hwClient hardware.HardwareClient
+	schedule *Schedule
+	music    *BackgroundMusic
+	config   PresetGuardConfig
+
+	mu      sync.Mutex
+	pending map[string]pendingConfirmation
+}
+
+func NewPresetGuard(hwClient hardware.HardwareClient, schedule *Schedule, music *BackgroundMusic, config PresetGuardConfig) *PresetGuard {
+	return &PresetGuard{
+		hwClient: hwClient,
+		schedule: schedule,
+		music:    music,
+		config:   config,
+		pending:  make(map[string]pendingConfirmation),
+	}
+}
+
+// Check evaluates the enabled rules and returns the reasons that apply.
+func (g *PresetGuard) Check(now time.Time) []GuardReason {
+	reasons := []GuardReason{}
+
+	for _, rule := range g.config.Rules {
+		switch rule {
+		case GuardRecorderActive:
+			status, err := g.hwClient.GetRecorderStatus()
+			if err == nil && status.State == "recording" {
+				reasons = append(reasons, GuardReason{
+					Code:    GuardRecorderActive,
+					Message: fmt.Sprintf("Recording in progress (%s)", status.Filename),
+				})
+			}
+		case GuardPlayerPlaying:
+			status, err := g.hwClient.GetPlayerStatus()
+			// Background music started by the server is meant to be interrupted
+			if err == nil && status.State == "playing" && (g.music == nil || !g.music.State().Active) {
+				reasons = append(reasons, GuardReason{
+					Code:    GuardPlayerPlaying,
+					Message: fmt.Sprintf("Music is playing (%s)", status.SongTitle),
+				})
+			}
+		case GuardServiceSoon:
+			if g.schedule == nil {
+				continue
+			}
+			if current := g.schedule.Current(now); current != nil {
+				reasons = append(reasons, GuardReason{
+					Code:    GuardServiceSoon,
+					Message: fmt.Sprintf("%s is in progress (until %s)", current.Name, current.EndsAt.Format("15:04")),
+				})
+			} else if next := g.schedule.Upcoming(now, g.config.ServiceWindow); next != nil {
+				reasons = append(reasons, GuardReason{
+					Code:    GuardServiceSoon,
+					Message: fmt.Sprintf("%s starts at %s", next.Name, next.StartsAt.Format("15:04")),
+				})
+			}
+		}
+	}
+
+	return reasons
+}
+
+// Issue creates a one-time confirmation token bound to user and preset.
+func (g *PresetGuard) Issue(presetID, userID string, now time.Time) (string, time.Time) {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	token := hex.EncodeToString(buf)
+	expiresAt := now.Add(confirmationTTL)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for t, p := range g.pending {
+		if now.After(p.expiresAt) {
+			delete(g.pending, t)
+		}
+	}
+	g.pending[token] = pendingConfirmation{presetID: presetID, userID: userID, expiresAt: expiresAt}
+
+	return token, expiresAt
+}
+
+// Confirm consumes token, reporting whether it was issued to this user for
+// this preset and has not expired.
+func (g *PresetGuard) Confirm(token, presetID, userID string, now time.Time) bool {
+	if token == "" {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	p, ok := g.pending[token]
+	if !ok {
+		return false
+	}
+	delete(g.pending, token)
+
+	return p.presetID == presetID && p.userID == userID && !now.After(p.expiresAt)
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"av-control/internal/hardware"
+	"av-control/internal/models"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// PresetView is a daemon preset merged with its models.PresetMeta overlay.
+type PresetView struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	DaemonName   string   `json:"daemon_name"`
+	Description  string   `json:"description,omitempty"`
+	Color        string   `json:"color,omitempty"`
+	Icon         string   `json:"icon,omitempty"`
+	SortOrder    int      `json:"sort_order"`
+	Favorite     bool     `json:"favorite"`
+	VisibleRoles []string `json:"visible_roles,omitempty"`
+}
+
+type PresetViewsResponse struct {
+	Presets []PresetView `json:"presets"`
+}
+
+// PresetDirectory merges the daemon preset list with the server-side
+// metadata overlay (display name, color, ordering, visibility per role) and
+// runs the post-load hooks configured for each preset.
+type PresetDirectory struct {
+	db        *gorm.DB
+	hwClient  hardware.HardwareClient
+	actions   *ActionExecutor
+	listeners []func(presetID string)
+
+	hookMu sync.Mutex // one hook chain at a time
+}
+
+func NewPresetDirectory(db *gorm.DB, hwClient hardware.HardwareClient, actions *ActionExecutor) *PresetDirectory {
+	return &PresetDirectory{db: db, hwClient: hwClient, actions: actions}
+}
+
+// List returns the presets visible to role: favorites first, then by
+// sort order, keeping the daemon order for ties. Admins see every preset.
+func (pd *PresetDirectory) List(role string) (*PresetViewsResponse, error) {
+	presets, err := pd.hwClient.GetPresets()
+	if err != nil {
+		return nil, err
+	}
+
+	metas, err := pd.metaByID()
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]PresetView, 0, len(presets.Presets))
+	for _, p := range presets.Presets {
+		view := PresetView{ID: p.ID, Name: p.Name, DaemonName: p.Name}
+		if meta, ok := metas[p.ID]; ok {
+			if meta.DisplayName != "" {
+				view.Name = meta.DisplayName
+			}
+			view.Description = meta.Description
+			view.Color = meta.Color
+			view.Icon = meta.Icon
+			view.SortOrder = meta.SortOrder
+			view.Favorite = meta.Favorite
+			view.VisibleRoles = SplitTags(meta.VisibleRoles)
+		}
+
+		if !roleAllowed(view.VisibleRoles, role) {
+			continue
+		}
+		views = append(views, view)
+	}
+
+	sort.SliceStable(views, func(i, j int) bool {
+		if views[i].Favorite != views[j].Favorite {
+			return views[i].Favorite
+		}
+		return views[i].SortOrder < views[j].SortOrder
+	})
+
+	return &PresetViewsResponse{Presets: views}, nil
+}
+
+// Visible reports whether role may see the preset, as List decides.
+func (pd *PresetDirectory) Visible(presetID, role string) (bool, error) {
+	var meta models.PresetMeta
+	result := pd.db.Where("preset_id = ?", presetID).Limit(1).Find(&meta)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return roleAllowed(SplitTags(meta.VisibleRoles), role), nil
+}
+
+// ListMeta returns every stored overlay, including presets the daemon no
+// longer reports.
+func (pd *PresetDirectory) ListMeta() ([]models.PresetMeta, error) {
+	var metas []models.PresetMeta
+	err := pd.db.Order("sort_order, preset_id").Find(&metas).Error
+	return metas, err
+}
+
+// SaveMeta creates or replaces the overlay for meta.PresetID.
+func (pd *PresetDirectory) SaveMeta(meta *models.PresetMeta) error {
+	meta.VisibleRoles = JoinTags(SplitTags(meta.VisibleRoles))
+	return pd.db.Save(meta).Error
+}
+
+// DeleteMeta removes the overlay, reverting the preset to the daemon defaults.
+func (pd *PresetDirectory) DeleteMeta(presetID string) error {
+	return pd.db.Delete(&models.PresetMeta{}, "preset_id = ?", presetID).Error
+}
+
+// Hooks returns the post-load hook of a preset (Enabled false and no
+// actions when none is configured).
+func (pd *PresetDirectory) Hooks(presetID string) (*models.PresetHook, []Action, error) {
+	hook := models.PresetHook{PresetID: presetID}
+	err := pd.db.Where("preset_id = ?", presetID).First(&hook).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+
+	actions, err := DecodeActions(hook.Actions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &hook, actions, nil
+}
+
+// SaveHooks validates and stores the post-load actions of a preset.
+func (pd *PresetDirectory) SaveHooks(presetID string, actions []Action, enabled bool) (*models.PresetHook, error) {
+	if err := pd.actions.Validate(actions); err != nil {
+		return nil, err
+	}
+
+	hook := models.PresetHook{
+		PresetID: presetID,
+		Actions:  EncodeActions(actions),
+		Enabled:  enabled,
+	}
+	return &hook, pd.db.Save(&hook).Error
+}
+
+func (pd *PresetDirectory) DeleteHooks(presetID string) error {
+	return pd.db.Delete(&models.PresetHook{}, "preset_id = ?", presetID).Error
+}
+
+// OnLoaded registers a callback run after every successful preset load.
+// Must be called before the server starts handling requests.
+func (pd *PresetDirectory) OnLoaded(fn func(presetID string)) {
+	pd.listeners = append(pd.listeners, fn)
+}
+
+// AfterLoad runs the preset's hooks in the background, then notifies the
+// listeners. Hooks may include delays, so the load request does not wait for
+// them: done receives the results once they finish. AfterLoad reports
+// whether the preset has hooks to run.
+func (pd *PresetDirectory) AfterLoad(presetID string, done func(results []ActionResult)) bool {
+	hook, actions, err := pd.Hooks(presetID)
+	if err != nil {
+		log.Printf("⚠️  Failed to read hooks for preset %s: %v", presetID, err)
+	}
+	pending := err == nil && hook.Enabled && len(actions) > 0
+
+	go func() {
+		pd.hookMu.Lock()
+		defer pd.hookMu.Unlock()
+
+		var results []ActionResult
+		if pending {
+			results = pd.runHooks(presetID, actions)
+		}
+		for _, fn := range pd.listeners {
+			fn(presetID)
+		}
+		if pending && done != nil {
+			done(results)
+		}
+	}()
+	return pending
+}
+
+func (pd *PresetDirectory) runHooks(presetID string, actions []Action) []ActionResult {
+	results := pd.actions.Run(actions)
+	for _, r := range results {
+		if !r.Success {
+			log.Printf("⚠️  Preset %s hook %s %s failed: %s", presetID, r.Type, r.Target, r.Error)
+		}
+	}
+	return results
+}
+
+func (pd *PresetDirectory) metaByID() (map[string]models.PresetMeta, error) {
+	metas, err := pd.ListMeta()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]models.PresetMeta, len(metas))
+	for _, meta := range metas {
+		byID[meta.PresetID] = meta
+	}
+	return byID, nil
+}
+
+func roleAllowed(roles []string, role string) bool {
+	if len(roles) == 0 || role == "admin" {
+		return true
+	}
+	for _, r := range roles {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
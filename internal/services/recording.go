@@ -0,0 +1,421 @@
+package services
+
+import (
+	"av-control/internal/hardware"
+	"av-control/internal/models"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidStopTime  = errors.New("stop time must be in the future")
+	ErrAlreadyRecording = errors.New("a recording is already in progress")
+)
+
+// A failed auto-stop is retried with exponential backoff, then given up with
+// a recording_stop_failed broadcast
+const (
+	autoStopRetryDelay  = 5 * time.Second
+	autoStopMaxAttempts = 5
+)
+
+// Auto-stop reasons reported in recording_auto_stopped broadcasts
+const (
+	StopReasonManual      = "manual"
+	StopReasonMaxDuration = "max_duration"
+	StopReasonScheduled   = "scheduled_end"
+	StopReasonPlayerIdle  = "player_idle"
+	StopReasonEndPreset   = "end_preset"
+)
+
+// RecordingLimits configures the server-side recording watchdog.
+type RecordingLimits struct {
+	MaxDuration   time.Duration // hard ceiling for every recording (0 = unlimited)
+	WarningBefore time.Duration // warning broadcast lead time before an auto-stop
+	IdleTimeout   time.Duration // stop once the player has been idle this long (0 = disabled)
+	EndPresets    []string      // loading one of these presets ends the service
+}
+
+type StartRecordingOptions struct {
+	Filename    string
+	UserID      string
+	Username    string
+	MaxDuration time.Duration // requested by the client (0 = server limit only)
+	StopAt      *time.Time    // scheduled end time (nil = none)
+}
+
+type RecordingInfo struct {
+	RecordingID uint       `json:"recording_id,omitempty"`
+	Filename    string     `json:"filename"`
+	StartedAt   time.Time  `json:"started_at"`
+	StopAt      *time.Time `json:"stop_at,omitempty"`
+	Warning     string     `json:"warning,omitempty"`
+}
+
+type activeRecording struct {
+	recordingID uint
+	filename    string
+	startedAt   time.Time
+	stopAt      time.Time
+	stopReason  string
+	warned      bool
+	startPreset string
+
+	// starting is set while the hardware start is in flight, stopping while
+	// an auto-stop is, so OnStatus neither adopts nor forgets the recording
+	starting bool
+	stopping bool
+
+	stopAttempts  int
+	nextStopRetry time.Time
+	stopGaveUp    bool
+
+	// Player idle tracking: the watchdog only arms after the player
+	// has played at least once during this recording.
+	playerPlayed    bool
+	playerIdleSince time.Time
+}
+
+// RecordingSupervisor wraps the hardware recorder so that no recording can
+// run forever: it enforces per-recording and global limits, broadcasts a
+// warning before stopping, and watches the polled status for signs that
+// the service has ended.
+type RecordingSupervisor struct {
+	hwClient hardware.HardwareClient
+	hub      *Hub
+	catalog  *RecordingCatalog
+	storage  *StorageGuard
+	limits   RecordingLimits
+	interval time.Duration
+
+	mu       sync.Mutex
+	active   *activeRecording
+	stopChan chan struct{}
+}
+
+func NewRecordingSupervisor(hwClient hardware.HardwareClient, hub *Hub, catalog *RecordingCatalog, storage *StorageGuard, limits RecordingLimits) *RecordingSupervisor {
+	return &RecordingSupervisor{
+		hwClient: hwClient,
+		hub:      hub,
+		catalog:  catalog,
+		storage:  storage,
+		limits:   limits,
+		interval: time.Second,
+		stopChan: make(chan struct{}),
+	}
+}
+
+func (s *RecordingSupervisor) Start() {
+	go s.watchLoop()
+	log.Printf("🎥 Recording supervisor started (max %s, warning %s before stop)", s.limits.MaxDuration, s.limits.WarningBefore)
+}
+
+func (s *RecordingSupervisor) Stop() {
+	close(s.stopChan)
+}
+
+// StartRecording starts the hardware recorder and arms the auto-stop deadline.
+func (s *RecordingSupervisor) StartRecording(opts StartRecordingOptions) (*RecordingInfo, error) {
+	now := time.Now()
+	if opts.StopAt != nil && !opts.StopAt.After(now) {
+		return nil, ErrInvalidStopTime
+	}
+
+	stopAt, reason := s.deadline(now, opts.MaxDuration, opts.StopAt)
+
+	// Pre-flight free space check
+	warning, err := s.storage.Preflight()
+	if err != nil {
+		return nil, err
+	}
+
+	// Claim the recorder before starting it, or a status poll in between
+	// would adopt the recording as started outside the server
+	rec := &activeRecording{
+		startedAt:  now,
+		stopAt:     stopAt,
+		stopReason: reason,
+		starting:   true,
+	}
+	s.mu.Lock()
+	if s.active != nil {
+		s.mu.Unlock()
+		return nil, ErrAlreadyRecording
+	}
+	s.active = rec
+	s.mu.Unlock()
+
+	filename, err := s.hwClient.StartRecording(opts.Filename)
+	if err != nil {
+		s.mu.Lock()
+		if s.active == rec {
+			s.active = nil
+		}
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	var startPreset string
+	if preset, err := s.hwClient.GetCurrentPreset(); err == nil {
+		startPreset = preset.ID
+	}
+	var recordingID uint
+	if stored := s.catalog.Begin(filename, opts.UserID, opts.Username, startPreset, now); stored != nil {
+		recordingID = stored.ID
+	}
+
+	s.mu.Lock()
+	rec.filename = filename
+	rec.startPreset = startPreset
+	rec.recordingID = recordingID
+	rec.starting = false
+	s.mu.Unlock()
+
+	if !stopAt.IsZero() {
+		log.Printf("🎥 [RECORDER] %s will auto-stop at %s (%s)", filename, stopAt.Format(time.RFC3339), reason)
+	}
+
+	info := rec.info()
+	info.Warning = warning
+	return info, nil
+}
+
+// StopRecording stops the hardware recorder, disarms the watchdog and
+// closes the catalog entry.
+func (s *RecordingSupervisor) StopRecording(userID string) (*models.Recording, error) {
+	if err := s.hwClient.StopRecording(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.active = nil
+	s.mu.Unlock()
+
+	return s.catalog.Finish(userID, StopReasonManual, time.Now()), nil
+}
+
+// Current returns the recording being supervised, if any.
+func (s *RecordingSupervisor) Current() *RecordingInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil || s.active.starting {
+		return nil
+	}
+	return s.active.info()
+}
+
+// deadline picks the earliest of the requested duration, the scheduled end
+// time and the global ceiling.
+func (s *RecordingSupervisor) deadline(start time.Time, maxDuration time.Duration, stopAt *time.Time) (time.Time, string) {
+	var deadline time.Time
+	reason := ""
+
+	consider := func(t time.Time, r string) {
+		if deadline.IsZero() || t.Before(deadline) {
+			deadline = t
+			reason = r
+		}
+	}
+
+	if s.limits.MaxDuration > 0 {
+		consider(start.Add(s.limits.MaxDuration), StopReasonMaxDuration)
+	}
+	if maxDuration > 0 {
+		consider(start.Add(maxDuration), StopReasonMaxDuration)
+	}
+	if stopAt != nil {
+		consider(*stopAt, StopReasonScheduled)
+	}
+
+	return deadline, reason
+}
+
+func (s *RecordingSupervisor) watchLoop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.checkDeadline(time.Now())
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+func (s *RecordingSupervisor) checkDeadline(now time.Time) {
+	s.mu.Lock()
+	rec := s.active
+	if rec == nil || rec.starting || rec.stopAt.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+
+	if now.Before(rec.stopAt) {
+		remaining := rec.stopAt.Sub(now)
+		sendWarning := !rec.warned && s.limits.WarningBefore > 0 && remaining <= s.limits.WarningBefore
+		if sendWarning {
+			rec.warned = true
+		}
+		s.mu.Unlock()
+
+		if sendWarning {
+			log.Printf("⚠️  [RECORDER] %s will auto-stop in %s", rec.filename, remaining.Round(time.Second))
+			s.hub.BroadcastRecordingWarning(RecordingAlertData{
+				Filename:         rec.filename,
+				Reason:           rec.stopReason,
+				StopAt:           rec.stopAt.Format(time.RFC3339),
+				SecondsRemaining: int(remaining.Seconds()),
+			})
+		}
+		return
+	}
+	s.mu.Unlock()
+
+	s.autoStop(rec, rec.stopReason)
+}
+
+// OnStatus implements StatusObserver. It adopts recordings started outside
+// the server, forgets recordings stopped elsewhere and runs the idle and
+// end-of-service checks.
+func (s *RecordingSupervisor) OnStatus(status *models.SystemStatus) {
+	now := time.Now()
+
+	s.mu.Lock()
+	rec := s.active
+	if rec != nil && (rec.starting || rec.stopping) {
+		s.mu.Unlock()
+		return
+	}
+
+	if status.Recorder.State != "recording" {
+		s.active = nil
+		s.mu.Unlock()
+
+		if rec != nil {
+			log.Printf("🎥 [RECORDER] %s stopped outside the server", rec.filename)
+			s.catalog.Finish("", StopReasonExternal, now)
+		}
+		return
+	}
+
+	if rec == nil {
+		startedAt := now.Add(-time.Duration(status.Recorder.CurrentTime) * time.Second)
+		stopAt, reason := s.deadline(startedAt, 0, nil)
+		rec = &activeRecording{
+			filename:    status.Recorder.Filename,
+			startedAt:   startedAt,
+			stopAt:      stopAt,
+			stopReason:  reason,
+			startPreset: status.Preset.ID,
+		}
+		if stored := s.catalog.Begin(rec.filename, "", "", rec.startPreset, startedAt); stored != nil {
+			rec.recordingID = stored.ID
+		}
+		s.active = rec
+		log.Printf("🎥 [RECORDER] Supervising recording started outside the server: %s", rec.filename)
+	}
+
+	reason := ""
+	if status.Player.State == "playing" {
+		rec.playerPlayed = true
+		rec.playerIdleSince = time.Time{}
+	} else if rec.playerPlayed {
+		if rec.playerIdleSince.IsZero() {
+			rec.playerIdleSince = now
+		}
+		if s.limits.IdleTimeout > 0 && now.Sub(rec.playerIdleSince) >= s.limits.IdleTimeout {
+			reason = StopReasonPlayerIdle
+		}
+	}
+
+	if status.Preset.ID != rec.startPreset && s.isEndPreset(status.Preset.ID) {
+		reason = StopReasonEndPreset
+	}
+	s.mu.Unlock()
+
+	if reason != "" {
+		s.autoStop(rec, reason)
+	}
+}
+
+func (s *RecordingSupervisor) isEndPreset(presetID string) bool {
+	for _, id := range s.limits.EndPresets {
+		if id == presetID {
+			return true
+		}
+	}
+	return false
+}
+
+// autoStop stops rec for reason. A failed stop is retried on later checks
+// with exponential backoff, up to autoStopMaxAttempts.
+func (s *RecordingSupervisor) autoStop(rec *activeRecording, reason string) {
+	now := time.Now()
+
+	s.mu.Lock()
+	if s.active != rec || rec.stopping || rec.stopGaveUp || now.Before(rec.nextStopRetry) {
+		// Already stopped or replaced in the meantime, or waiting to retry
+		s.mu.Unlock()
+		return
+	}
+	rec.stopping = true
+	s.mu.Unlock()
+
+	log.Printf("🛑 [RECORDER] Auto-stopping %s (%s)", rec.filename, reason)
+	err := s.hwClient.StopRecording()
+
+	s.mu.Lock()
+	rec.stopping = false
+	if err != nil {
+		rec.stopAttempts++
+		attempts := rec.stopAttempts
+		if attempts >= autoStopMaxAttempts {
+			rec.stopGaveUp = true
+		} else {
+			rec.nextStopRetry = now.Add(autoStopRetryDelay * time.Duration(1<<(attempts-1)))
+		}
+		s.mu.Unlock()
+
+		if attempts < autoStopMaxAttempts {
+			log.Printf("❌ [RECORDER] Auto-stop failed (attempt %d/%d): %v", attempts, autoStopMaxAttempts, err)
+			return
+		}
+		log.Printf("❌ [RECORDER] Auto-stop of %s failed %d times, giving up: %v", rec.filename, attempts, err)
+		s.hub.BroadcastRecordingStopFailed(RecordingAlertData{
+			Filename: rec.filename,
+			Reason:   reason,
+			Attempts: attempts,
+			Error:    err.Error(),
+		})
+		return
+	}
+	if s.active == rec {
+		s.active = nil
+	}
+	s.mu.Unlock()
+
+	s.catalog.Finish("", reason, time.Now())
+
+	s.hub.BroadcastRecordingAutoStopped(RecordingAlertData{
+		Filename: rec.filename,
+		Reason:   reason,
+	})
+}
+
+func (r *activeRecording) info() *RecordingInfo {
+	info := &RecordingInfo{
+		RecordingID: r.recordingID,
+		Filename:    r.filename,
+		StartedAt:   r.startedAt,
+	}
+	if !r.stopAt.IsZero() {
+		stopAt := r.stopAt
+		info.StopAt = &stopAt
+	}
+	return info
+}
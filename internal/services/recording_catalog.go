@@ -0,0 +1,188 @@
+package services
+
+import (
+	"av-control/internal/models"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const StopReasonExternal = "external"
+
+// RecordingCatalog persists every recording as a models.Recording so that
+// past recordings can be searched and downloaded after they stop.
+type RecordingCatalog struct {
+	db        *gorm.DB
+	listeners []func(rec *models.Recording)
+}
+
+type RecordingFilter struct {
+	Query    string // matched against filename and notes
+	Tag      string
+	UserID   string
+	From     *time.Time
+	To       *time.Time
+	Page     int
+	PageSize int
+}
+
+func NewRecordingCatalog(db *gorm.DB) *RecordingCatalog {
+	return &RecordingCatalog{db: db}
+}
+
+// OnFinished registers a callback run (asynchronously) for every recording
+// that is closed. Must be called before recordings start.
+func (rc *RecordingCatalog) OnFinished(fn func(rec *models.Recording)) {
+	rc.listeners = append(rc.listeners, fn)
+}
+
+// Begin stores a new open recording. An open recording with the same
+// filename is reused (server restarted mid-recording); any other open
+// recording is closed first, since the device records one file at a time.
+func (rc *RecordingCatalog) Begin(filename, userID, username, presetID string, startedAt time.Time) *models.Recording {
+	var open models.Recording
+	if err := rc.db.Where("stopped_at IS NULL AND filename = ?", filename).First(&open).Error; err == nil {
+		return &open
+	}
+	rc.Finish("", StopReasonExternal, startedAt)
+
+	rec := &models.Recording{
+		Filename:      filename,
+		StartedAt:     startedAt,
+		StartedBy:     userID,
+		StartedByName: username,
+		PresetID:      presetID,
+	}
+	if err := rc.db.Create(rec).Error; err != nil {
+		log.Printf("❌ Failed to store recording %s: %v", filename, err)
+		return nil
+	}
+	return rec
+}
+
+// Finish closes the open recording, if any, and returns it.
+func (rc *RecordingCatalog) Finish(userID, reason string, stoppedAt time.Time) *models.Recording {
+	var rec models.Recording
+	if err := rc.db.Where("stopped_at IS NULL").Order("started_at DESC").First(&rec).Error; err != nil {
+		return nil
+	}
+
+	duration := int(stoppedAt.Sub(rec.StartedAt).Seconds())
+	if duration < 0 {
+		duration = 0
+	}
+
+	updates := map[string]interface{}{
+		"stopped_at":       stoppedAt,
+		"duration_seconds": duration,
+		"stopped_by":       userID,
+		"stop_reason":      reason,
+	}
+	if err := rc.db.Model(&rec).Updates(updates).Error; err != nil {
+		log.Printf("❌ Failed to close recording %s: %v", rec.Filename, err)
+		return nil
+	}
+	rec.StoppedAt = &stoppedAt
+	rec.DurationSeconds = duration
+	rec.StoppedBy = userID
+	rec.StopReason = reason
+
+	// Older open rows can only come from an unclean shutdown
+	rc.db.Model(&models.Recording{}).Where("stopped_at IS NULL").
+		Updates(map[string]interface{}{"stopped_at": stoppedAt, "stop_reason": StopReasonExternal})
+
+	for _, fn := range rc.listeners {
+		finished := rec
+		go fn(&finished)
+	}
+
+	return &rec
+}
+
+func (rc *RecordingCatalog) Get(id uint) (*models.Recording, error) {
+	var rec models.Recording
+	if err := rc.db.First(&rec, id).Error; err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (rc *RecordingCatalog) List(f RecordingFilter) ([]models.Recording, int64, error) {
+	q := rc.db.Model(&models.Recording{})
+
+	if f.Query != "" {
+		like := "%" + strings.ToLower(f.Query) + "%"
+		q = q.Where("(LOWER(filename) LIKE ? OR LOWER(notes) LIKE ?)", like, like)
+	}
+	if f.Tag != "" {
+		q = q.Where("(',' || LOWER(tags) || ',') LIKE ?", "%,"+strings.ToLower(f.Tag)+",%")
+	}
+	if f.UserID != "" {
+		q = q.Where("started_by = ?", f.UserID)
+	}
+	if f.From != nil {
+		q = q.Where("started_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		q = q.Where("started_at <= ?", *f.To)
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var recs []models.Recording
+	err := q.Order("started_at DESC").
+		Offset((f.Page - 1) * f.PageSize).
+		Limit(f.PageSize).
+		Find(&recs).Error
+	return recs, total, err
+}
+
+// UpdateMetadata replaces tags and/or notes. Nil arguments are left unchanged.
+func (rc *RecordingCatalog) UpdateMetadata(id uint, tags []string, notes *string) (*models.Recording, error) {
+	rec, err := rc.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if tags != nil {
+		updates["tags"] = JoinTags(tags)
+	}
+	if notes != nil {
+		updates["notes"] = *notes
+	}
+	if len(updates) > 0 {
+		if err := rc.db.Model(rec).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	return rc.Get(id)
+}
+
+// JoinTags normalizes a tag list into the stored comma separated form.
+func JoinTags(tags []string) string {
+	seen := make(map[string]bool)
+	var clean []string
+	for _, t := range tags {
+		t = strings.TrimSpace(strings.ReplaceAll(t, ",", " "))
+		if t == "" || seen[strings.ToLower(t)] {
+			continue
+		}
+		seen[strings.ToLower(t)] = true
+		clean = append(clean, t)
+	}
+	return strings.Join(clean, ",")
+}
+
+// SplitTags is the inverse of JoinTags.
+func SplitTags(tags string) []string {
+	if tags == "" {
+		return []string{}
+	}
+	return strings.Split(tags, ",")
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"av-control/internal/hardware"
+	"av-control/internal/models"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrTemplateNotFound = errors.New("recording template not found")
+
+const (
+	maxFilenameLength = 120
+	defaultExtension  = ".mp3"
+)
+
+// audioExtensions are the extensions a name may already end with. Anything
+// else after a dot is part of the name ("Messa 10.30").
+var audioExtensions = map[string]bool{
+	".mp3": true, ".wav": true, ".flac": true, ".ogg": true, ".opus": true,
+	".m4a": true, ".aac": true, ".wma": true, ".aif": true, ".aiff": true,
+}
+
+// audioExt returns the extension of name if it is a known audio one.
+func audioExt(name string) string {
+	ext := filepath.Ext(name)
+	if audioExtensions[strings.ToLower(ext)] {
+		return ext
+	}
+	return ""
+}
+
+// NameRequest holds everything a filename template can refer to.
+type NameRequest struct {
+	Filename string // free-form name from the client, may contain tokens
+	Template string // template name, empty = default template
+	Event    string
+	Username string
+	Now      time.Time
+}
+
+// RecordingNamer turns templates and client-supplied names into safe,
+// unique recording filenames.
+type RecordingNamer struct {
+	db            *gorm.DB
+	hwClient      hardware.HardwareClient
+	recordingsDir string
+}
+
+func NewRecordingNamer(db *gorm.DB, hwClient hardware.HardwareClient, recordingsDir string) *RecordingNamer {
+	return &RecordingNamer{
+		db:            db,
+		hwClient:      hwClient,
+		recordingsDir: recordingsDir,
+	}
+}
+
+// Resolve returns the filename to pass to the hardware recorder. An empty
+// result means no template applies and the daemon should pick the name.
+func (n *RecordingNamer) Resolve(req NameRequest) (string, error) {
+	pattern := req.Filename
+	if pattern == "" {
+		tmpl, err := n.template(req.Template)
+		if err != nil {
+			return "", err
+		}
+		if tmpl == nil {
+			return "", nil
+		}
+		pattern = tmpl.Pattern
+	}
+
+	name := SanitizeFilename(n.Expand(pattern, req))
+	if name == "" {
+		return "", nil
+	}
+	return n.unique(name), nil
+}
+
+func (n *RecordingNamer) template(name string) (*models.RecordingTemplate, error) {
+	var tmpl models.RecordingTemplate
+	if name != "" {
+		if err := n.db.Where("name = ?", name).First(&tmpl).Error; err != nil {
+			return nil, ErrTemplateNotFound
+		}
+		return &tmpl, nil
+	}
+
+	if err := n.db.Where("is_default = ?", true).First(&tmpl).Error; err != nil {
+		return nil, nil
+	}
+	return &tmpl, nil
+}
+
+// Expand replaces template tokens. Unknown tokens are left untouched and
+// later neutralized by SanitizeFilename.
+func (n *RecordingNamer) Expand(pattern string, req NameRequest) string {
+	now := req.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	replacer := strings.NewReplacer(
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("15-04"),
+		"{datetime}", now.Format("2006-01-02_15-04"),
+		"{weekday}", weekdayNames[now.Weekday()],
+		"{preset}", n.presetName(),
+		"{event}", req.Event,
+		"{user}", req.Username,
+	)
+	return replacer.Replace(pattern)
+}
+
+var weekdayNames = [...]string{"domenica", "lunedi", "martedi", "mercoledi", "giovedi", "venerdi", "sabato"}
+
+func (n *RecordingNamer) presetName() string {
+	current, err := n.hwClient.GetCurrentPreset()
+	if err != nil || current.ID == "" {
+		return ""
+	}
+
+	if presets, err := n.hwClient.GetPresets(); err == nil {
+		for _, p := range presets.Presets {
+			if p.ID == current.ID && p.Name != "" {
+				return p.Name
+			}
+		}
+	}
+	return strings.TrimSuffix(current.ID, filepath.Ext(current.ID))
+}
+
+// unique appends _2, _3, ... until the name collides with neither a
+// catalogued recording nor a file in the recordings directory.
+func (n *RecordingNamer) unique(name string) string {
+	ext := audioExt(name)
+	base := strings.TrimSuffix(name, ext)
+
+	candidate := name
+	for i := 2; n.exists(candidate); i++ {
+		candidate = fmt.Sprintf("%s_%d%s", base, i, ext)
+	}
+	return candidate
+}
+
+func (n *RecordingNamer) exists(name string) bool {
+	var count int64
+	n.db.Model(&models.Recording{}).Where("filename = ?", name).Count(&count)
+	if count > 0 {
+		return true
+	}
+
+	if n.recordingsDir != "" {
+		if _, err := os.Stat(filepath.Join(n.recordingsDir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// SanitizeFilename strips directories, accents and anything that is not a
+// letter, digit, dot, dash or underscore, and guarantees an audio extension.
+func SanitizeFilename(name string) string {
+	name = foldAccents(strings.TrimSpace(name))
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			b.WriteRune(r)
+		default:
+			// Path separators, spaces, control and reserved characters
+			b.WriteRune('_')
+		}
+	}
+
+	clean := b.String()
+	for strings.Contains(clean, "..") {
+		clean = strings.ReplaceAll(clean, "..", ".")
+	}
+	for strings.Contains(clean, "__") {
+		clean = strings.ReplaceAll(clean, "__", "_")
+	}
+	clean = strings.Trim(clean, "._-")
+
+	ext := audioExt(clean)
+	base := strings.TrimSuffix(clean, ext)
+	if base == "" {
+		return ""
+	}
+	if ext == "" {
+		ext = defaultExtension
+	}
+
+	if len(base)+len(ext) > maxFilenameLength {
+		base = strings.TrimRight(base[:maxFilenameLength-len(ext)], "._-")
+	}
+	return base + ext
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Messa domenicale", "Messa_domenicale.mp3"},
+		{"accents", "Santità è già qui", "Santita_e_gia_qui.mp3"},
+		{"known extension kept", "Vespri.wav", "Vespri.wav"},
+		{"extension case kept", "Vespri.FLAC", "Vespri.FLAC"},
+		{"time is not an extension", "Messa 10.30", "Messa_10.30.mp3"},
+		{"date is not an extension", "Rosario 2026.10.18", "Rosario_2026.10.18.mp3"},
+		{"unknown extension", "note.txt", "note.txt.mp3"},
+		{"path traversal", "../../etc/passwd", "etc_passwd.mp3"},
+		{"windows separators", `C:\registrazioni\messa.mp3`, "C_registrazioni_messa.mp3"},
+		{"reserved characters", `a<b>c:d"e|f?g*h`, "a_b_c_d_e_f_g_h.mp3"},
+		{"repeated separators", "a  -- b...c", "a_--_b.c.mp3"},
+		{"only punctuation", "../.. / ..", ""},
+		{"empty", "", ""},
+		{"extension only", ".mp3", "mp3.mp3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFilename(tt.in); got != tt.want {
+				t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilenameLength(t *testing.T) {
+	got := SanitizeFilename(strings.Repeat("a", 200) + ".wav")
+	if len(got) != maxFilenameLength || !strings.HasSuffix(got, ".wav") {
+		t.Errorf("SanitizeFilename(long) = %q (%d chars), want %d chars ending in .wav", got, len(got), maxFilenameLength)
+	}
+}
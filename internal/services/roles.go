@@ -0,0 +1,173 @@
+package services
+
+import (
+	"av-control/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// AdminRole holds every permission and cannot be deleted; only its
+// description and session limit can be edited.
+const AdminRole = "admin"
+
+var (
+	ErrRoleNotFound = errors.New("role not found")
+	ErrRoleInUse    = errors.New("role is assigned to users")
+	ErrRoleBuiltIn  = errors.New("built-in roles cannot be deleted")
+	ErrInvalidRole  = errors.New("invalid role")
+)
+
+// RoleStore caches the role -> permissions bundles so permission checks do
+// not hit the database on every request.
+type RoleStore struct {
+	db *gorm.DB
+
+	mu    sync.RWMutex
+	roles map[string]models.Role
+}
+
+func NewRoleStore(db *gorm.DB) *RoleStore {
+	s := &RoleStore{db: db}
+	s.Reload()
+	return s
+}
+
+// Reload reads the roles again from the database.
+func (s *RoleStore) Reload() {
+	var roles []models.Role
+	if err := s.db.Find(&roles).Error; err != nil {
+		log.Printf("⚠️  Failed to load roles: %v", err)
+		return
+	}
+
+	byName := make(map[string]models.Role, len(roles))
+	for _, r := range roles {
+		byName[r.Name] = r
+	}
+
+	s.mu.Lock()
+	s.roles = byName
+	s.mu.Unlock()
+}
+
+// Role returns the cached role.
+func (s *RoleStore) Role(name string) (models.Role, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.roles[name]
+	return r, ok
+}
+
+// Exists reports whether role is defined.
+func (s *RoleStore) Exists(role string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.roles[role]
+	return ok
+}
+
+// Permissions returns the permissions granted to role.
+func (s *RoleStore) Permissions(role string) []string {
+	if role == AdminRole {
+		all := make([]string, 0, len(models.AllPermissions))
+		for _, p := range models.AllPermissions {
+			all = append(all, p.Name)
+		}
+		return all
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return SplitTags(s.roles[role].Permissions)
+}
+
+// Has reports whether role grants permission.
+func (s *RoleStore) Has(role, permission string) bool {
+	if role == AdminRole {
+		return true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return containsString(SplitTags(s.roles[role].Permissions), permission)
+}
+
+// List returns every role.
+func (s *RoleStore) List() ([]models.Role, error) {
+	var roles []models.Role
+	err := s.db.Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// Save creates or updates a role after checking its permissions. The
+// admin role only accepts its description and session settings.
+func (s *RoleStore) Save(role *models.Role) error {
+	if role.Name == AdminRole {
+		role.Permissions = ""
+	}
+	if role.MaxSessions < 0 {
+		return fmt.Errorf("%w: max_sessions must not be negative", ErrInvalidRole)
+	}
+	if role.SessionLimitMode == "" {
+		role.SessionLimitMode = SessionEvictOldest
+	}
+	if role.SessionLimitMode != SessionEvictOldest && role.SessionLimitMode != SessionReject {
+		return fmt.Errorf("%w: session_limit_mode must be %q or %q", ErrInvalidRole, SessionEvictOldest, SessionReject)
+	}
+	for _, p := range SplitTags(role.Permissions) {
+		if !isPermission(p) {
+			return fmt.Errorf("%w: unknown permission %q", ErrInvalidRole, p)
+		}
+	}
+
+	var existing models.Role
+	if err := s.db.Where("name = ?", role.Name).Limit(1).Find(&existing).Error; err != nil {
+		return err
+	}
+	role.BuiltIn = existing.BuiltIn
+	if err := s.db.Save(role).Error; err != nil {
+		return err
+	}
+
+	s.Reload()
+	return nil
+}
+
+// Delete removes a custom role that no user holds.
+func (s *RoleStore) Delete(name string) error {
+	var role models.Role
+	if err := s.db.Where("name = ?", name).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRoleNotFound
+		}
+		return err
+	}
+	if role.BuiltIn {
+		return ErrRoleBuiltIn
+	}
+
+	var users int64
+	s.db.Model(&models.User{}).Where("role = ?", name).Count(&users)
+	if users > 0 {
+		return ErrRoleInUse
+	}
+
+	if err := s.db.Delete(&role).Error; err != nil {
+		return err
+	}
+	s.Reload()
+	return nil
+}
+
+func isPermission(name string) bool {
+	for _, p := range models.AllPermissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
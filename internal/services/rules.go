@@ -0,0 +1,465 @@
+package services
+
+import (
+	"av-control/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Rule trigger types
+const (
+	TriggerState   = "state"
+	TriggerCommand = "command"
+	TriggerTime    = "time"
+)
+
+const (
+	ruleTickInterval = 15 * time.Second
+	ruleMinInterval  = 2 * time.Second // floor between two firings of a rule, breaks action loops
+	ruleQueueSize    = 50
+	ruleHistorySize  = 50
+)
+
+// RuleStateFields are the status fields a state trigger or condition can use.
+var RuleStateFields = []string{"player.state", "player.song", "recorder.state", "preset", "connected"}
+
+var ErrInvalidRule = errors.New("invalid rule")
+
+// RuleEvent is something that happened on the device. The engine builds
+// events from status transitions, executed commands and the clock; the
+// test endpoint accepts them to simulate a trigger.
+type RuleEvent struct {
+	Type    string    `json:"type"`
+	Field   string    `json:"field,omitempty"`
+	From    string    `json:"from,omitempty"`
+	To      string    `json:"to,omitempty"`
+	Command string    `json:"command,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// RuleFiring records a rule that fired.
+type RuleFiring struct {
+	RuleID   uint           `json:"rule_id"`
+	RuleName string         `json:"rule_name"`
+	Event    RuleEvent      `json:"event"`
+	DryRun   bool           `json:"dry_run"`
+	FiredAt  time.Time      `json:"fired_at"`
+	Results  []ActionResult `json:"results,omitempty"`
+}
+
+// RuleEvaluation tells whether a rule would fire for an event, without
+// running anything.
+type RuleEvaluation struct {
+	TriggerMatched bool     `json:"trigger_matched"`
+	ConditionsMet  bool     `json:"conditions_met"`
+	WouldFire      bool     `json:"would_fire"`
+	Reasons        []string `json:"reasons,omitempty"` // why it would not fire
+	Actions        []Action `json:"actions"`
+}
+
+type compiledRule struct {
+	models.AutomationRule
+	require map[string]string
+	actions []Action
+}
+
+// RuleEngine evaluates the automation rules. Status transitions come from
+// the StatusPoller, commands from the Hub and time triggers from an
+// internal ticker. Matching rules are queued and run one at a time by a
+// worker, so a rule with delays never blocks polling or a request.
+type RuleEngine struct {
+	db      *gorm.DB
+	actions *ActionExecutor
+	hub     *Hub
+
+	mu         sync.Mutex
+	rules      []compiledRule
+	state      map[string]string
+	lastFired  map[uint]time.Time
+	lastMinute string
+	history    []RuleFiring
+
+	queue    chan RuleFiring
+	stopChan chan struct{}
+}
+
+func NewRuleEngine(db *gorm.DB, actions *ActionExecutor, hub *Hub) *RuleEngine {
+	return &RuleEngine{
+		db:        db,
+		actions:   actions,
+		hub:       hub,
+		lastFired: make(map[uint]time.Time),
+		queue:     make(chan RuleFiring, ruleQueueSize),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+func (e *RuleEngine) Start() {
+	e.Reload()
+	e.lastMinute = time.Now().Format("2006-01-02 15:04")
+	go e.worker()
+	go e.tickLoop()
+	log.Printf("⚙️  Rule engine started (%d rules)", len(e.rules))
+}
+
+func (e *RuleEngine) Stop() {
+	close(e.stopChan)
+}
+
+// Reload reads the enabled rules again after a change.
+func (e *RuleEngine) Reload() {
+	var rules []models.AutomationRule
+	if err := e.db.Where("enabled = ?", true).Order("id").Find(&rules).Error; err != nil {
+		log.Printf("⚠️  Failed to load automation rules: %v", err)
+		return
+	}
+
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, r := range rules {
+		c, err := compileRule(&r)
+		if err != nil {
+			log.Printf("⚠️  Skipping rule %q: %v", r.Name, err)
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+
+	e.mu.Lock()
+	e.rules = compiled
+	e.mu.Unlock()
+}
+
+// History returns the most recent firings, newest first.
+func (e *RuleEngine) History() []RuleFiring {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	history := make([]RuleFiring, 0, len(e.history))
+	for i := len(e.history) - 1; i >= 0; i-- {
+		history = append(history, e.history[i])
+	}
+	return history
+}
+
+// OnStatus implements StatusObserver: every changed field is a state event.
+// The first status only records the baseline.
+func (e *RuleEngine) OnStatus(status *models.SystemStatus) {
+	fields := statusFields(status)
+	now := time.Now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	previous := e.state
+	e.state = fields
+	if previous == nil {
+		return
+	}
+	for _, field := range RuleStateFields {
+		if previous[field] != fields[field] {
+			e.dispatch(RuleEvent{Type: TriggerState, Field: field, From: previous[field], To: fields[field], At: now})
+		}
+	}
+}
+
+// OnCommand is registered on the Hub and receives every executed command.
+func (e *RuleEngine) OnCommand(cmd CommandExecutedData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.dispatch(RuleEvent{Type: TriggerCommand, Command: cmd.Command, At: time.Now()})
+}
+
+// Evaluate reports whether rule would fire for ev against the current
+// device state. A state event also sets its field to the new value.
+func (e *RuleEngine) Evaluate(rule *models.AutomationRule, ev RuleEvent) (RuleEvaluation, error) {
+	c, err := compileRule(rule)
+	if err != nil {
+		return RuleEvaluation{}, err
+	}
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+
+	e.mu.Lock()
+	state := make(map[string]string, len(e.state)+1)
+	for k, v := range e.state {
+		state[k] = v
+	}
+	last, fired := e.lastFired[rule.ID]
+	e.mu.Unlock()
+
+	if ev.Type == TriggerState && ev.Field != "" {
+		state[ev.Field] = ev.To
+	}
+
+	result := RuleEvaluation{Actions: c.actions}
+	result.TriggerMatched = c.matches(ev)
+	if !result.TriggerMatched {
+		result.Reasons = append(result.Reasons, "trigger does not match the event")
+	}
+	conditions := c.failedConditions(ev.At, state)
+	result.ConditionsMet = len(conditions) == 0
+	result.Reasons = append(result.Reasons, conditions...)
+	if !rule.Enabled {
+		result.Reasons = append(result.Reasons, "rule is disabled")
+	}
+	if fired && rule.ID != 0 && ev.At.Before(last.Add(c.cooldown())) {
+		result.Reasons = append(result.Reasons, "rule is cooling down")
+	}
+	result.WouldFire = len(result.Reasons) == 0
+	return result, nil
+}
+
+// Validate checks a rule before it is saved.
+func (e *RuleEngine) Validate(rule *models.AutomationRule) error {
+	c, err := compileRule(rule)
+	if err != nil {
+		return err
+	}
+	if len(c.actions) == 0 {
+		return fmt.Errorf("%w: at least one action is required", ErrInvalidRule)
+	}
+	if err := e.actions.Validate(c.actions); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	return nil
+}
+
+// dispatch queues every rule matching ev. Callers hold e.mu.
+func (e *RuleEngine) dispatch(ev RuleEvent) {
+	for i := range e.rules {
+		r := &e.rules[i]
+		if !r.matches(ev) || len(r.failedConditions(ev.At, e.state)) > 0 {
+			continue
+		}
+		if last, ok := e.lastFired[r.ID]; ok && ev.At.Before(last.Add(r.cooldown())) {
+			continue
+		}
+		e.lastFired[r.ID] = ev.At
+
+		firing := RuleFiring{RuleID: r.ID, RuleName: r.Name, Event: ev, DryRun: r.DryRun, FiredAt: ev.At}
+		select {
+		case e.queue <- firing:
+		default:
+			log.Printf("⚠️  Rule queue full, dropping %q", r.Name)
+		}
+	}
+}
+
+func (e *RuleEngine) tickLoop() {
+	ticker := time.NewTicker(ruleTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			minute := now.Format("2006-01-02 15:04")
+			e.mu.Lock()
+			if minute != e.lastMinute {
+				e.lastMinute = minute
+				e.dispatch(RuleEvent{Type: TriggerTime, At: now})
+			}
+			e.mu.Unlock()
+		case <-e.stopChan:
+			return
+		}
+	}
+}
+
+func (e *RuleEngine) worker() {
+	for {
+		select {
+		case firing := <-e.queue:
+			e.run(firing)
+		case <-e.stopChan:
+			return
+		}
+	}
+}
+
+func (e *RuleEngine) run(firing RuleFiring) {
+	e.mu.Lock()
+	var rule *compiledRule
+	for i := range e.rules {
+		if e.rules[i].ID == firing.RuleID {
+			r := e.rules[i]
+			rule = &r
+		}
+	}
+	e.mu.Unlock()
+	if rule == nil {
+		return // deleted or disabled meanwhile
+	}
+
+	if firing.DryRun {
+		log.Printf("🧪 Rule %q matched (%s), dry run: %d actions skipped", rule.Name, describeEvent(firing.Event), len(rule.actions))
+	} else {
+		firing.Results = e.actions.Run(rule.actions)
+		log.Printf("⚙️  Rule %q fired (%s)", rule.Name, describeEvent(firing.Event))
+	}
+
+	e.db.Model(&models.AutomationRule{}).Where("id = ?", rule.ID).Update("last_fired_at", firing.FiredAt)
+
+	e.mu.Lock()
+	e.history = append(e.history, firing)
+	if len(e.history) > ruleHistorySize {
+		e.history = e.history[len(e.history)-ruleHistorySize:]
+	}
+	e.mu.Unlock()
+
+	e.hub.BroadcastRuleFired(firing)
+}
+
+func compileRule(r *models.AutomationRule) (compiledRule, error) {
+	invalid := func(format string, args ...interface{}) (compiledRule, error) {
+		return compiledRule{}, fmt.Errorf("%w: %s", ErrInvalidRule, fmt.Sprintf(format, args...))
+	}
+
+	switch r.TriggerType {
+	case TriggerState:
+		if !containsString(RuleStateFields, r.TriggerField) {
+			return invalid("trigger_field must be one of %s", strings.Join(RuleStateFields, ", "))
+		}
+		if r.TriggerFrom == "" && r.TriggerTo == "" {
+			return invalid("a state trigger needs trigger_from or trigger_to")
+		}
+	case TriggerCommand:
+		if r.TriggerCommand == "" {
+			return invalid("trigger_command is required")
+		}
+	case TriggerTime:
+		if _, err := ParseClock(r.TriggerAt); err != nil {
+			return invalid("trigger_at: %v", err)
+		}
+	default:
+		return invalid("trigger_type must be state, command or time")
+	}
+
+	for _, clock := range []string{r.After, r.Before} {
+		if clock == "" {
+			continue
+		}
+		if _, err := ParseClock(clock); err != nil {
+			return invalid("%v", err)
+		}
+	}
+	for _, d := range SplitTags(r.Weekdays) {
+		if n, err := strconv.Atoi(d); err != nil || n < 0 || n > 6 {
+			return invalid("weekdays must be numbers from 0 (Sunday) to 6")
+		}
+	}
+
+	c := compiledRule{AutomationRule: *r}
+	if r.RequireState != "" {
+		if err := json.Unmarshal([]byte(r.RequireState), &c.require); err != nil {
+			return invalid("require_state: %v", err)
+		}
+		for field := range c.require {
+			if !containsString(RuleStateFields, field) {
+				return invalid("require_state field %q is unknown", field)
+			}
+		}
+	}
+
+	actions, err := DecodeActions(r.Actions)
+	if err != nil {
+		return invalid("%v", err)
+	}
+	c.actions = actions
+	return c, nil
+}
+
+func (r *compiledRule) matches(ev RuleEvent) bool {
+	if r.TriggerType != ev.Type {
+		return false
+	}
+	switch ev.Type {
+	case TriggerState:
+		return r.TriggerField == ev.Field &&
+			(r.TriggerFrom == "" || strings.EqualFold(r.TriggerFrom, ev.From)) &&
+			(r.TriggerTo == "" || strings.EqualFold(r.TriggerTo, ev.To))
+	case TriggerCommand:
+		if prefix, ok := strings.CutSuffix(r.TriggerCommand, "*"); ok {
+			return strings.HasPrefix(ev.Command, prefix)
+		}
+		return r.TriggerCommand == ev.Command
+	case TriggerTime:
+		return r.TriggerAt == ev.At.Format("15:04")
+	}
+	return false
+}
+
+// failedConditions lists the conditions that do not hold at now.
+func (r *compiledRule) failedConditions(now time.Time, state map[string]string) []string {
+	var failed []string
+	if !weekdayAllowed(r.Weekdays, int(now.Weekday())) {
+		failed = append(failed, "not on an allowed weekday")
+	}
+	if !clockInRange(r.After, r.Before, now) {
+		failed = append(failed, "outside the time window")
+	}
+	for field, want := range r.require {
+		if !strings.EqualFold(state[field], want) {
+			failed = append(failed, fmt.Sprintf("%s is %q, not %q", field, state[field], want))
+		}
+	}
+	return failed
+}
+
+func (r *compiledRule) cooldown() time.Duration {
+	d := time.Duration(r.CooldownSeconds) * time.Second
+	if d < ruleMinInterval {
+		return ruleMinInterval
+	}
+	return d
+}
+
+// clockInRange reports whether now falls in [after, before). Either bound
+// may be empty; a window with after > before crosses midnight.
+func clockInRange(after, before string, now time.Time) bool {
+	minute := now.Hour()*60 + now.Minute()
+	start, startErr := ParseClock(after)
+	end, endErr := ParseClock(before)
+
+	switch {
+	case startErr != nil && endErr != nil:
+		return true
+	case endErr != nil:
+		return minute >= start
+	case startErr != nil:
+		return minute < end
+	case start <= end:
+		return minute >= start && minute < end
+	default:
+		return minute >= start || minute < end
+	}
+}
+
+func statusFields(status *models.SystemStatus) map[string]string {
+	return map[string]string{
+		"player.state":   status.Player.State,
+		"player.song":    status.Player.SongTitle,
+		"recorder.state": status.Recorder.State,
+		"preset":         status.Preset.ID,
+		"connected":      strconv.FormatBool(status.Connected),
+	}
+}
+
+func describeEvent(ev RuleEvent) string {
+	switch ev.Type {
+	case TriggerState:
+		return fmt.Sprintf("%s %q -> %q", ev.Field, ev.From, ev.To)
+	case TriggerCommand:
+		return "command " + ev.Command
+	default:
+		return "time " + ev.At.Format("15:04")
+	}
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"av-control/internal/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const week = 7 * 24 * time.Hour
+
+// ServiceOccurrence is one concrete occurrence of a models.ScheduledService.
+type ServiceOccurrence struct {
+	ServiceID uint      `json:"service_id"`
+	Name      string    `json:"name"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	PresetID  string    `json:"preset_id,omitempty"`
+}
+
+// Schedule expands the stored services (including weekly ones) into
+// occurrences so other services can ask what is on now or coming up.
+type Schedule struct {
+	db *gorm.DB
+}
+
+func NewSchedule(db *gorm.DB) *Schedule {
+	return &Schedule{db: db}
+}
+
+// Occurrences returns every occurrence overlapping [from, to), sorted by
+// start time.
+func (s *Schedule) Occurrences(from, to time.Time) ([]ServiceOccurrence, error) {
+	var services []models.ScheduledService
+	if err := s.db.Where("weekly = ? OR starts_at < ?", true, to).Find(&services).Error; err != nil {
+		return nil, err
+	}
+
+	var occurrences []ServiceOccurrence
+	for _, svc := range services {
+		duration := time.Duration(svc.DurationMinutes) * time.Minute
+		start := svc.StartsAt.Local()
+
+		if svc.Weekly {
+			// Jump close to the window, then walk week by week in local time
+			// so daylight saving changes keep the wall-clock time.
+			if skip := int(from.Sub(start.Add(duration)) / week); skip > 0 {
+				start = start.AddDate(0, 0, 7*skip)
+			}
+			for ; start.Before(to); start = start.AddDate(0, 0, 7) {
+				if start.Add(duration).After(from) {
+					occurrences = append(occurrences, occurrence(&svc, start, duration))
+				}
+			}
+			continue
+		}
+
+		if start.Before(to) && start.Add(duration).After(from) {
+			occurrences = append(occurrences, occurrence(&svc, start, duration))
+		}
+	}
+
+	sort.Slice(occurrences, func(i, j int) bool {
+		return occurrences[i].StartsAt.Before(occurrences[j].StartsAt)
+	})
+	return occurrences, nil
+}
+
+// Current returns the occurrence in progress at now, if any.
+func (s *Schedule) Current(now time.Time) *ServiceOccurrence {
+	occurrences, err := s.Occurrences(now, now.Add(time.Second))
+	if err != nil {
+		return nil
+	}
+	for i := range occurrences {
+		if !occurrences[i].StartsAt.After(now) {
+			return &occurrences[i]
+		}
+	}
+	return nil
+}
+
+// Upcoming returns the first occurrence starting within the next window.
+func (s *Schedule) Upcoming(now time.Time, within time.Duration) *ServiceOccurrence {
+	occurrences, err := s.Occurrences(now, now.Add(within))
+	if err != nil {
+		return nil
+	}
+	for i := range occurrences {
+		if occurrences[i].StartsAt.After(now) {
+			return &occurrences[i]
+		}
+	}
+	return nil
+}
+
+func occurrence(svc *models.ScheduledService, start time.Time, duration time.Duration) ServiceOccurrence {
+	return ServiceOccurrence{
+		ServiceID: svc.ID,
+		Name:      svc.Name,
+		StartsAt:  start,
+		EndsAt:    start.Add(duration),
+		PresetID:  svc.PresetID,
+	}
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"av-control/internal/models"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session limit modes (models.Role.SessionLimitMode)
+const (
+	SessionEvictOldest = "evict_oldest"
+	SessionReject      = "reject"
+)
+
+var (
+	ErrSessionLimit    = errors.New("too many active sessions")
+	ErrSessionNotFound = errors.New("session not found")
+	ErrRefreshReused   = errors.New("refresh token was already used")
+
+	errNotCurrent = errors.New("not the current refresh token")
+)
+
+// TokenRotation carries the tokens that replace a session's current ones.
+type TokenRotation struct {
+	AccessHash       string
+	RefreshHash      string
+	ExpiresAt        time.Time
+	RefreshExpiresAt time.Time
+}
+
+// SessionManager applies the per-role concurrent session policy and lists
+// and revokes sessions.
+type SessionManager struct {
+	db         *gorm.DB
+	roles      *RoleStore
+	defaultMax int
+}
+
+func NewSessionManager(db *gorm.DB, roles *RoleStore, defaultMax int) *SessionManager {
+	return &SessionManager{db: db, roles: roles, defaultMax: defaultMax}
+}
+
+// Limit returns the maximum number of sessions and the limit mode of role.
+// A maximum of 0 means unlimited.
+func (m *SessionManager) Limit(role string) (int, string) {
+	max, mode := m.defaultMax, SessionEvictOldest
+	if r, ok := m.roles.Role(role); ok {
+		if r.MaxSessions > 0 {
+			max = r.MaxSessions
+		}
+		if r.SessionLimitMode != "" {
+			mode = r.SessionLimitMode
+		}
+	}
+	return max, mode
+}
+
+// Admit makes room for a new session of user, ending the least recently
+// used ones or returning ErrSessionLimit depending on the role policy.
+func (m *SessionManager) Admit(user *models.User) error {
+	max, mode := m.Limit(user.Role)
+	if max <= 0 {
+		return nil
+	}
+
+	active, err := m.Active(user.ID)
+	if err != nil {
+		return err
+	}
+	if len(active) < max {
+		return nil
+	}
+	if mode == SessionReject {
+		return ErrSessionLimit
+	}
+
+	// Active is sorted by last activity, most recent first
+	for _, s := range active[max-1:] {
+		m.db.Delete(&s)
+	}
+	return nil
+}
+
+// Active returns the user's unexpired sessions, most recently used first.
+// An empty userID returns every active session. A session whose access
+// token expired is still active while it can be refreshed.
+func (m *SessionManager) Active(userID string) ([]models.Session, error) {
+	query := m.db.Where("refresh_expires_at > ?", time.Now())
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var sessions []models.Session
+	err := query.Order("last_activity DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// Revoke ends one session. When userID is set the session must belong to
+// that user.
+func (m *SessionManager) Revoke(sessionID, userID string) error {
+	query := m.db.Where("id = ?", sessionID)
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	result := query.Delete(&models.Session{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeUser ends every session of the user, except keep when set.
+func (m *SessionManager) RevokeUser(userID, keep string) (int64, error) {
+	query := m.db.Where("user_id = ?", userID)
+	if keep != "" {
+		query = query.Where("id <> ?", keep)
+	}
+	result := query.Delete(&models.Session{})
+	return result.RowsAffected, result.Error
+}
+
+// Rotate swaps the session's refresh token for a new one. Each refresh
+// token works once: presenting a rotated token again revokes its session,
+// since either the client or an attacker holds a stolen copy.
+func (m *SessionManager) Rotate(userID, refreshHash string, next TokenRotation) (*models.Session, error) {
+	now := time.Now()
+	var session models.Session
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("refresh_token_hash = ? AND user_id = ?", refreshHash, userID).Limit(1).Find(&session)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errNotCurrent
+		}
+		if !session.RefreshExpiresAt.After(now) {
+			return ErrSessionNotFound
+		}
+
+		// The hash condition makes a concurrent refresh with the same token
+		// fail here instead of both succeeding
+		result = tx.Model(&models.Session{}).
+			Where("id = ? AND refresh_token_hash = ?", session.ID, refreshHash).
+			Updates(map[string]interface{}{
+				"token_hash":         next.AccessHash,
+				"refresh_token_hash": next.RefreshHash,
+				"expires_at":         next.ExpiresAt,
+				"refresh_expires_at": next.RefreshExpiresAt,
+				"last_activity":      now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errNotCurrent
+		}
+
+		return tx.Create(&models.UsedRefreshToken{
+			TokenHash: refreshHash,
+			SessionID: session.ID,
+			UserID:    session.UserID,
+			UsedAt:    now,
+			ExpiresAt: session.RefreshExpiresAt,
+		}).Error
+	})
+	if errors.Is(err, errNotCurrent) {
+		return nil, m.checkReuse(refreshHash)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Used tokens are only needed until they would have expired
+	m.db.Where("expires_at < ?", now).Delete(&models.UsedRefreshToken{})
+	return &session, nil
+}
+
+// checkReuse revokes the session a rotated refresh token belonged to.
+func (m *SessionManager) checkReuse(refreshHash string) error {
+	var used models.UsedRefreshToken
+	result := m.db.Where("token_hash = ?", refreshHash).Limit(1).Find(&used)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+
+	log.Printf("⚠️  Refresh token reused for session %s of user %s, revoking the session", used.SessionID, used.UserID)
+	if err := m.db.Where("id = ?", used.SessionID).Delete(&models.Session{}).Error; err != nil {
+		return err
+	}
+	return ErrRefreshReused
+}
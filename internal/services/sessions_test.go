@@ -0,0 +1,155 @@
+package services
+
+import (
+	"av-control/internal/models"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestSessionDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Session{}, &models.UsedRefreshToken{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSessionManagerRotate(t *testing.T) {
+	rotation := func(n string) TokenRotation {
+		return TokenRotation{
+			AccessHash:       "access-" + n,
+			RefreshHash:      "refresh-" + n,
+			ExpiresAt:        time.Now().Add(time.Hour),
+			RefreshExpiresAt: time.Now().Add(24 * time.Hour),
+		}
+	}
+
+	// Each step presents a refresh token; a successful one rotates it to
+	// refresh-<next>
+	type step struct {
+		user    string
+		refresh string
+		next    string
+		wantErr error
+	}
+	tests := []struct {
+		name        string
+		refreshLeft time.Duration // refresh expiry of the session at login
+		steps       []step
+		wantSession bool // session still there at the end
+	}{
+		{
+			name:        "chain of refreshes",
+			refreshLeft: time.Hour,
+			steps: []step{
+				{"u1", "refresh-0", "1", nil},
+				{"u1", "refresh-1", "2", nil},
+				{"u1", "refresh-2", "3", nil},
+			},
+			wantSession: true,
+		},
+		{
+			name:        "reused token revokes the session",
+			refreshLeft: time.Hour,
+			steps: []step{
+				{"u1", "refresh-0", "1", nil},
+				{"u1", "refresh-0", "x", ErrRefreshReused},
+				// The legitimate client's current token is now useless too
+				{"u1", "refresh-1", "2", ErrSessionNotFound},
+			},
+			wantSession: false,
+		},
+		{
+			name:        "older token reused after several rotations",
+			refreshLeft: time.Hour,
+			steps: []step{
+				{"u1", "refresh-0", "1", nil},
+				{"u1", "refresh-1", "2", nil},
+				{"u1", "refresh-0", "x", ErrRefreshReused},
+			},
+			wantSession: false,
+		},
+		{
+			name:        "unknown token",
+			refreshLeft: time.Hour,
+			steps: []step{
+				{"u1", "refresh-forged", "1", ErrSessionNotFound},
+				{"u1", "refresh-0", "1", nil},
+			},
+			wantSession: true,
+		},
+		{
+			name:        "token of another user",
+			refreshLeft: time.Hour,
+			steps: []step{
+				{"u2", "refresh-0", "1", ErrSessionNotFound},
+				{"u1", "refresh-0", "1", nil},
+			},
+			wantSession: true,
+		},
+		{
+			name:        "expired session",
+			refreshLeft: -time.Minute,
+			steps: []step{
+				{"u1", "refresh-0", "1", ErrSessionNotFound},
+			},
+			wantSession: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestSessionDB(t)
+			m := NewSessionManager(db, nil, 0)
+
+			session := models.Session{
+				ID:               "s1",
+				UserID:           "u1",
+				TokenHash:        "access-0",
+				RefreshTokenHash: "refresh-0",
+				ExpiresAt:        time.Now().Add(time.Hour),
+				RefreshExpiresAt: time.Now().Add(tt.refreshLeft),
+				LastActivity:     time.Now(),
+			}
+			if err := db.Create(&session).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			for i, s := range tt.steps {
+				_, err := m.Rotate(s.user, s.refresh, rotation(s.next))
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("step %d: Rotate(%q, %q) = %v, want %v", i, s.user, s.refresh, err, s.wantErr)
+				}
+				if err != nil {
+					continue
+				}
+
+				var stored models.Session
+				if err := db.First(&stored, "id = ?", "s1").Error; err != nil {
+					t.Fatalf("step %d: %v", i, err)
+				}
+				if stored.RefreshTokenHash != "refresh-"+s.next || stored.TokenHash != "access-"+s.next {
+					t.Errorf("step %d: session has %q/%q, want the rotated tokens", i, stored.TokenHash, stored.RefreshTokenHash)
+				}
+			}
+
+			var count int64
+			db.Model(&models.Session{}).Where("id = ?", "s1").Count(&count)
+			if (count == 1) != tt.wantSession {
+				t.Errorf("session exists = %v, want %v", count == 1, tt.wantSession)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"av-control/internal/hardware"
+	"av-control/internal/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+const unknownSource = -1
+
+// SongResult is a song from the index with its tags.
+type SongResult struct {
+	ID   int      `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+type SongQuery struct {
+	Query    string
+	Tag      string
+	Page     int
+	PageSize int
+}
+
+type SongSearchResponse struct {
+	SourceID *int         `json:"source_id,omitempty"`
+	Songs    []SongResult `json:"songs"`
+	Total    int          `json:"total"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+}
+
+type indexedSong struct {
+	song       models.Song
+	normalized string
+	words      []string
+}
+
+type songCache struct {
+	songs     []indexedSong
+	fetchedAt time.Time
+}
+
+// SongIndex caches the daemon song list per source and searches it
+// ignoring case and accents. The cache of a source is dropped when a
+// source is selected, and refreshed after ttl in any case.
+type SongIndex struct {
+	db       *gorm.DB
+	hwClient hardware.HardwareClient
+	ttl      time.Duration
+
+	mu            sync.Mutex
+	currentSource int
+	cache         map[int]*songCache
+}
+
+func NewSongIndex(db *gorm.DB, hwClient hardware.HardwareClient, ttl time.Duration) *SongIndex {
+	return &SongIndex{
+		db:            db,
+		hwClient:      hwClient,
+		ttl:           ttl,
+		currentSource: unknownSource,
+		cache:         make(map[int]*songCache),
+	}
+}
+
+// SourceSelected records the new current source and invalidates its
+// cached list (the daemon rescans the medium on selection).
+func (si *SongIndex) SourceSelected(sourceID int) {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	si.currentSource = sourceID
+	delete(si.cache, sourceID)
+}
+
+// Invalidate drops every cached list.
+func (si *SongIndex) Invalidate() {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	si.cache = make(map[int]*songCache)
+}
+
+// Songs returns the current source's songs sorted by name.
+func (si *SongIndex) Songs() (*models.SongsResponse, error) {
+	indexed, _, err := si.load()
+	if err != nil {
+		return nil, err
+	}
+
+	songs := make([]models.Song, 0, len(indexed))
+	for _, s := range indexed {
+		songs = append(songs, s.song)
+	}
+	return &models.SongsResponse{Songs: songs}, nil
+}
+
+// Search filters the current source's songs by text and tag. Results are
+// ranked exact > title prefix > word prefix > substring > fuzzy match.
+func (si *SongIndex) Search(q SongQuery) (*SongSearchResponse, error) {
+	indexed, sourceID, err := si.load()
+	if err != nil {
+		return nil, err
+	}
+
+	tagsBySong, err := si.tagsBySong()
+	if err != nil {
+		return nil, err
+	}
+
+	query := normalizeTitle(q.Query)
+	tag := strings.ToLower(strings.TrimSpace(q.Tag))
+
+	type ranked struct {
+		song  *indexedSong
+		score int
+	}
+	var matches []ranked
+	for i := range indexed {
+		s := &indexed[i]
+		if tag != "" && !hasTag(tagsBySong[s.song.Name], tag) {
+			continue
+		}
+		score := 1
+		if query != "" {
+			if score = matchScore(s, query); score == 0 {
+				continue
+			}
+		}
+		matches = append(matches, ranked{song: s, score: score})
+	}
+
+	// indexed is already sorted by name, so a stable sort keeps ties alphabetical
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	response := &SongSearchResponse{
+		Songs:    []SongResult{},
+		Total:    len(matches),
+		Page:     q.Page,
+		PageSize: q.PageSize,
+	}
+	if sourceID != unknownSource {
+		response.SourceID = &sourceID
+	}
+
+	start := (q.Page - 1) * q.PageSize
+	for i := start; i < len(matches) && i < start+q.PageSize; i++ {
+		s := matches[i].song
+		tags := tagsBySong[s.song.Name]
+		if tags == nil {
+			tags = []string{}
+		}
+		response.Songs = append(response.Songs, SongResult{ID: s.song.ID, Name: s.song.Name, Tags: tags})
+	}
+	return response, nil
+}
+
+// Tags lists every tag in use.
+func (si *SongIndex) Tags() ([]string, error) {
+	tags := []string{}
+	err := si.db.Model(&models.SongTag{}).Distinct("tag").Order("tag").Pluck("tag", &tags).Error
+	return tags, err
+}
+
+// SetTags replaces the tags of a song.
+func (si *SongIndex) SetTags(songName string, tags []string) ([]string, error) {
+	clean := SplitTags(JoinTags(tags))
+
+	err := si.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("song_name = ?", songName).Delete(&models.SongTag{}).Error; err != nil {
+			return err
+		}
+		for _, t := range clean {
+			if err := tx.Create(&models.SongTag{SongName: songName, Tag: t}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return clean, err
+}
+
+func (si *SongIndex) load() ([]indexedSong, int, error) {
+	si.mu.Lock()
+	sourceID := si.currentSource
+	if cached, ok := si.cache[sourceID]; ok && time.Since(cached.fetchedAt) < si.ttl {
+		si.mu.Unlock()
+		return cached.songs, sourceID, nil
+	}
+	si.mu.Unlock()
+
+	response, err := si.hwClient.GetSongs()
+	if err != nil {
+		return nil, sourceID, err
+	}
+
+	indexed := make([]indexedSong, 0, len(response.Songs))
+	for _, song := range response.Songs {
+		normalized := normalizeTitle(song.Name)
+		indexed = append(indexed, indexedSong{
+			song:       song,
+			normalized: normalized,
+			words:      strings.Fields(normalized),
+		})
+	}
+	sort.SliceStable(indexed, func(i, j int) bool {
+		return indexed[i].normalized < indexed[j].normalized
+	})
+
+	si.mu.Lock()
+	// A source change while fetching makes this list stale for the new source
+	if si.currentSource == sourceID {
+		si.cache[sourceID] = &songCache{songs: indexed, fetchedAt: time.Now()}
+	}
+	si.mu.Unlock()
+
+	return indexed, sourceID, nil
+}
+
+func (si *SongIndex) tagsBySong() (map[string][]string, error) {
+	var rows []models.SongTag
+	if err := si.db.Order("tag").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	tags := make(map[string][]string)
+	for _, r := range rows {
+		tags[r.SongName] = append(tags[r.SongName], r.Tag)
+	}
+	return tags, nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.ToLower(t) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTitle lowercases, folds accents and turns punctuation into
+// spaces ("Pietà, Signore!" -> "pieta signore").
+func normalizeTitle(s string) string {
+	folded := strings.ToLower(foldAccents(s))
+	mapped := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, folded)
+	return strings.Join(strings.Fields(mapped), " ")
+}
+
+func matchScore(s *indexedSong, query string) int {
+	switch {
+	case s.normalized == query:
+		return 100
+	case strings.HasPrefix(s.normalized, query):
+		return 90
+	}
+
+	queryWords := strings.Fields(query)
+	if len(queryWords) == 1 {
+		for _, w := range s.words {
+			if strings.HasPrefix(w, query) {
+				return 80
+			}
+		}
+	}
+
+	if strings.Contains(s.normalized, query) {
+		return 60
+	}
+
+	// Fuzzy: every query word close to some title word (typos, missing letters)
+	best := 0
+	for _, qw := range queryWords {
+		allowed := len([]rune(qw)) / 4
+		closest := allowed + 1
+		for _, w := range s.words {
+			candidate := w
+			if r := []rune(w); len(r) > len([]rune(qw)) {
+				// Compare against the word's prefix so partial words still match
+				candidate = string(r[:len([]rune(qw))])
+			}
+			if d := editDistance(qw, candidate); d < closest {
+				closest = d
+			}
+		}
+		if closest > allowed {
+			return 0
+		}
+		best += closest
+	}
+	return 40 - best
+}
+
+// editDistance is the optimal string alignment distance: insertions,
+// deletions, substitutions and adjacent transpositions ("hotle") cost 1.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
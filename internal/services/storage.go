@@ -0,0 +1,302 @@
+package services
+
+import (
+	"av-control/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInsufficientStorage = errors.New("not enough free disk space to start recording")
+
+// RetentionPolicy configures which recordings are removed from disk and
+// when free space is considered low.
+type RetentionPolicy struct {
+	KeepDays      int    // delete recordings older than this (0 = keep forever)
+	MaxTotalBytes uint64 // delete oldest recordings above this total (0 = unlimited)
+	KeepTagged    bool   // tagged recordings are never deleted
+	MinFreeBytes  uint64 // refuse to start recording below this
+	WarnFreeBytes uint64 // warn (disk_low) below this
+	Interval      time.Duration
+}
+
+type StorageStatus struct {
+	Path           string `json:"path"`
+	FreeBytes      uint64 `json:"free_bytes"`
+	TotalBytes     uint64 `json:"total_bytes"`
+	RecordingBytes uint64 `json:"recording_bytes"`
+	RecordingFiles int    `json:"recording_files"`
+	Low            bool   `json:"low"`
+	Critical       bool   `json:"critical"`
+}
+
+type RetentionCandidate struct {
+	Filename string    `json:"filename"`
+	Size     uint64    `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	Reason   string    `json:"reason"` // age, total_size
+}
+
+// StorageGuard enforces the retention policy over the recordings directory
+// and watches free disk space.
+type StorageGuard struct {
+	db     *gorm.DB
+	hub    *Hub
+	dir    string
+	policy RetentionPolicy
+
+	mu        sync.Mutex
+	lastAlert time.Time
+	stopChan  chan struct{}
+}
+
+func NewStorageGuard(db *gorm.DB, hub *Hub, dir string, policy RetentionPolicy) *StorageGuard {
+	if policy.Interval <= 0 {
+		policy.Interval = time.Hour
+	}
+	return &StorageGuard{
+		db:       db,
+		hub:      hub,
+		dir:      dir,
+		policy:   policy,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Enabled reports whether a recordings directory is configured.
+func (g *StorageGuard) Enabled() bool {
+	return g != nil && g.dir != ""
+}
+
+func (g *StorageGuard) Policy() RetentionPolicy {
+	return g.policy
+}
+
+func (g *StorageGuard) Start() {
+	if !g.Enabled() {
+		log.Println("💾 Storage guard disabled (RECORDINGS_DIR not set)")
+		return
+	}
+	go g.loop()
+	log.Printf("💾 Storage guard started on %s", g.dir)
+}
+
+func (g *StorageGuard) Stop() {
+	close(g.stopChan)
+}
+
+func (g *StorageGuard) loop() {
+	g.runOnce()
+
+	ticker := time.NewTicker(g.policy.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			g.runOnce()
+		case <-g.stopChan:
+			return
+		}
+	}
+}
+
+func (g *StorageGuard) runOnce() {
+	if _, err := g.Enforce(false); err != nil {
+		log.Printf("❌ Retention run failed: %v", err)
+	}
+	if status, err := g.Status(); err == nil && status.Low {
+		g.alert(status, false)
+	}
+}
+
+// Status reports disk usage of the recordings directory.
+func (g *StorageGuard) Status() (*StorageStatus, error) {
+	free, total, err := diskUsage(g.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &StorageStatus{
+		Path:       g.dir,
+		FreeBytes:  free,
+		TotalBytes: total,
+		Low:        g.policy.WarnFreeBytes > 0 && free < g.policy.WarnFreeBytes,
+		Critical:   g.policy.MinFreeBytes > 0 && free < g.policy.MinFreeBytes,
+	}
+
+	files, err := g.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		status.RecordingBytes += f.Size
+	}
+	status.RecordingFiles = len(files)
+	return status, nil
+}
+
+// Preflight is called before a recording starts. It fails when free space
+// is below MinFreeBytes and returns a warning message below WarnFreeBytes.
+func (g *StorageGuard) Preflight() (string, error) {
+	if !g.Enabled() {
+		return "", nil
+	}
+
+	status, err := g.Status()
+	if err != nil {
+		// Never block a service because the check itself failed
+		log.Printf("⚠️  Disk space check failed: %v", err)
+		return "", nil
+	}
+
+	if status.Critical {
+		g.alert(status, true)
+		return "", ErrInsufficientStorage
+	}
+	if status.Low {
+		g.alert(status, true)
+		return "Low disk space: " + formatBytes(status.FreeBytes) + " free", nil
+	}
+	return "", nil
+}
+
+// alert broadcasts disk_low, at most once per hour unless forced.
+func (g *StorageGuard) alert(status *StorageStatus, force bool) {
+	g.mu.Lock()
+	if !force && time.Since(g.lastAlert) < time.Hour {
+		g.mu.Unlock()
+		return
+	}
+	g.lastAlert = time.Now()
+	g.mu.Unlock()
+
+	log.Printf("⚠️  Disk space low on %s: %s free", status.Path, formatBytes(status.FreeBytes))
+	g.hub.BroadcastDiskLow(DiskAlertData{
+		Path:       status.Path,
+		FreeBytes:  status.FreeBytes,
+		TotalBytes: status.TotalBytes,
+		Threshold:  g.policy.WarnFreeBytes,
+		Critical:   status.Critical,
+	})
+}
+
+// Enforce applies the retention policy. With dryRun the candidates are only
+// returned, nothing is deleted.
+func (g *StorageGuard) Enforce(dryRun bool) ([]RetentionCandidate, error) {
+	files, err := g.files()
+	if err != nil {
+		return nil, err
+	}
+
+	// Protect the recording in progress, recordings not archived yet (failed
+	// ones too, until an operator clears them) and, if configured, tagged ones
+	protected := make(map[string]bool)
+	var open []models.Recording
+	g.db.Where("stopped_at IS NULL").Find(&open)
+	for _, r := range open {
+		protected[r.Filename] = true
+	}
+	var unarchived []models.Recording
+	g.db.Where("archive_status IN ?", []string{ArchivePending, ArchiveRunning, ArchiveFailed}).Find(&unarchived)
+	for _, r := range unarchived {
+		protected[r.Filename] = true
+	}
+	if g.policy.KeepTagged {
+		var tagged []models.Recording
+		g.db.Where("tags <> ''").Find(&tagged)
+		for _, r := range tagged {
+			protected[r.Filename] = true
+		}
+	}
+
+	// Oldest first
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime.Before(files[j].ModTime) })
+
+	var total uint64
+	for _, f := range files {
+		total += f.Size
+	}
+
+	var candidates []RetentionCandidate
+	cutoff := time.Now().AddDate(0, 0, -g.policy.KeepDays)
+	for _, f := range files {
+		if protected[f.Filename] {
+			continue
+		}
+
+		switch {
+		case g.policy.KeepDays > 0 && f.ModTime.Before(cutoff):
+			f.Reason = "age"
+		case g.policy.MaxTotalBytes > 0 && total > g.policy.MaxTotalBytes:
+			f.Reason = "total_size"
+		default:
+			continue
+		}
+
+		candidates = append(candidates, f)
+		total -= f.Size
+	}
+
+	if dryRun {
+		return candidates, nil
+	}
+
+	for _, c := range candidates {
+		if err := os.Remove(filepath.Join(g.dir, c.Filename)); err != nil {
+			log.Printf("❌ Retention: failed to delete %s: %v", c.Filename, err)
+			continue
+		}
+		now := time.Now()
+		g.db.Model(&models.Recording{}).Where("filename = ?", c.Filename).Update("file_purged_at", &now)
+		log.Printf("🗑️  Retention: deleted %s (%s, %s)", c.Filename, c.Reason, formatBytes(c.Size))
+	}
+
+	return candidates, nil
+}
+
+// files lists the recordings in the directory. Only audio files count, so
+// retention never deletes anything else kept there.
+func (g *StorageGuard) files() ([]RetentionCandidate, error) {
+	entries, err := os.ReadDir(g.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []RetentionCandidate
+	for _, e := range entries {
+		if !e.Type().IsRegular() || audioExt(e.Name()) == "" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, RetentionCandidate{
+			Filename: e.Name(),
+			Size:     uint64(info.Size()),
+			ModTime:  info.ModTime(),
+		})
+	}
+	return files, nil
+}
+
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
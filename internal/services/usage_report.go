@@ -0,0 +1,145 @@
+package services
+
+import (
+	"av-control/internal/models"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SongUsage aggregates the plays of one song in a report period.
+type SongUsage struct {
+	SongTitle     string    `json:"song_title"`
+	Plays         int       `json:"plays"`
+	SecondsPlayed int       `json:"seconds_played"`
+	FirstPlayed   time.Time `json:"first_played"`
+	LastPlayed    time.Time `json:"last_played"`
+	Services      []string  `json:"services"`
+	Tags          []string  `json:"tags"`
+}
+
+// UsageReport lists every song played publicly in [From, To).
+type UsageReport struct {
+	From         time.Time   `json:"from"`
+	To           time.Time   `json:"to"`
+	TotalPlays   int         `json:"total_plays"`
+	TotalSeconds int         `json:"total_seconds"`
+	Songs        []SongUsage `json:"songs"`
+	GeneratedAt  time.Time   `json:"generated_at"`
+}
+
+// BuildUsageReport groups the play log of the period by song, most played
+// first.
+func BuildUsageReport(db *gorm.DB, from, to time.Time) (*UsageReport, error) {
+	var plays []models.PlayLog
+	if err := db.Where("started_at >= ? AND started_at < ?", from, to).Order("started_at").Find(&plays).Error; err != nil {
+		return nil, err
+	}
+
+	var tagRows []models.SongTag
+	if err := db.Order("tag").Find(&tagRows).Error; err != nil {
+		return nil, err
+	}
+	tags := make(map[string][]string)
+	for _, t := range tagRows {
+		tags[t.SongName] = append(tags[t.SongName], t.Tag)
+	}
+
+	report := &UsageReport{From: from, To: to, Songs: []SongUsage{}, GeneratedAt: time.Now()}
+	bySong := make(map[string]*SongUsage)
+	for _, p := range plays {
+		usage, ok := bySong[p.SongTitle]
+		if !ok {
+			usage = &SongUsage{
+				SongTitle:   p.SongTitle,
+				FirstPlayed: p.StartedAt,
+				Services:    []string{},
+				Tags:        tags[p.SongTitle],
+			}
+			if usage.Tags == nil {
+				usage.Tags = []string{}
+			}
+			bySong[p.SongTitle] = usage
+		}
+
+		usage.Plays++
+		usage.SecondsPlayed += p.SecondsPlayed
+		usage.LastPlayed = p.StartedAt
+		if p.ServiceName != "" && !containsFold(usage.Services, p.ServiceName) {
+			usage.Services = append(usage.Services, p.ServiceName)
+		}
+
+		report.TotalPlays++
+		report.TotalSeconds += p.SecondsPlayed
+	}
+
+	for _, usage := range bySong {
+		report.Songs = append(report.Songs, *usage)
+	}
+	sort.Slice(report.Songs, func(i, j int) bool {
+		a, b := report.Songs[i], report.Songs[j]
+		if a.Plays != b.Plays {
+			return a.Plays > b.Plays
+		}
+		return normalizeTitle(a.SongTitle) < normalizeTitle(b.SongTitle)
+	})
+
+	return report, nil
+}
+
+// WriteCSV writes one row per song, suitable for licensing submissions.
+func (r *UsageReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"title", "plays", "seconds_played", "first_played", "last_played", "services", "tags"})
+	for _, s := range r.Songs {
+		cw.Write([]string{
+			s.SongTitle,
+			strconv.Itoa(s.Plays),
+			strconv.Itoa(s.SecondsPlayed),
+			s.FirstPlayed.Local().Format(time.RFC3339),
+			s.LastPlayed.Local().Format(time.RFC3339),
+			strings.Join(s.Services, "; "),
+			strings.Join(s.Tags, "; "),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteText writes a plain-text table for printing or e-mailing.
+func (r *UsageReport) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "Music usage report %s - %s\n", r.From.Local().Format("02/01/2006"), r.To.Add(-time.Second).Local().Format("02/01/2006"))
+	fmt.Fprintf(w, "%d plays, %d songs, %s played\n\n", r.TotalPlays, len(r.Songs), formatPlayed(r.TotalSeconds))
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TITLE\tPLAYS\tPLAYED\tFIRST\tLAST")
+	for _, s := range r.Songs {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n",
+			s.SongTitle,
+			s.Plays,
+			formatPlayed(s.SecondsPlayed),
+			s.FirstPlayed.Local().Format("02/01/2006 15:04"),
+			s.LastPlayed.Local().Format("02/01/2006 15:04"))
+	}
+	return tw.Flush()
+}
+
+func formatPlayed(seconds int) string {
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"av-control/internal/models"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// User audit actions
+const (
+	UserActionCreate         = "create"
+	UserActionUpdate         = "update"
+	UserActionRoleChange     = "role_change"
+	UserActionDelete         = "delete"
+	UserActionPasswordReset  = "password_reset"
+	UserActionPasswordChange = "password_change"
+	UserActionActivate       = "activate"
+	UserActionDeactivate     = "deactivate"
+	UserActionLoginFailed    = "login_failed"
+	UserActionLockout        = "lockout"
+	UserActionIPLockout      = "ip_lockout"
+	UserActionUnlock         = "unlock"
+)
+
+// FieldChange is one entry of the UserAuditLog.Changes diff.
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// UserChangeEntry describes one change to an account.
+type UserChangeEntry struct {
+	Action          string
+	TargetUserID    string
+	TargetUsername  string
+	PerformedBy     string
+	PerformedByName string
+	ClientIP        string
+	Changes         map[string]FieldChange
+}
+
+// UserChanges diffs the audited fields of two states of a user. before is
+// nil for a create, after is nil for a delete. The password hash is never
+// included; password actions record a "password" entry instead.
+func UserChanges(before, after *models.User) map[string]FieldChange {
+	fields := func(u *models.User) map[string]interface{} {
+		if u == nil {
+			return map[string]interface{}{}
+		}
+		return map[string]interface{}{
+			"username":             u.Username,
+			"role":                 u.Role,
+			"full_name":            u.FullName,
+			"email":                u.Email,
+			"is_active":            u.IsActive,
+			"must_change_password": u.MustChangePassword,
+		}
+	}
+
+	b, a := fields(before), fields(after)
+	changes := map[string]FieldChange{}
+	for _, name := range []string{"username", "role", "full_name", "email", "is_active", "must_change_password"} {
+		if b[name] != a[name] {
+			changes[name] = FieldChange{Before: b[name], After: a[name]}
+		}
+	}
+	return changes
+}
+
+// LogUserChange writes the entry at once: account changes are rare and
+// must not be lost the way a queued command log entry could be.
+func (s *AuditService) LogUserChange(entry UserChangeEntry) {
+	changes, _ := json.Marshal(entry.Changes)
+	record := models.UserAuditLog{
+		Action:          entry.Action,
+		TargetUserID:    entry.TargetUserID,
+		TargetUsername:  entry.TargetUsername,
+		PerformedBy:     entry.PerformedBy,
+		PerformedByName: entry.PerformedByName,
+		ClientIP:        entry.ClientIP,
+		Changes:         string(changes),
+		Timestamp:       time.Now(),
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		log.Printf("❌ Failed to write user audit log: %v", err)
+	}
+}
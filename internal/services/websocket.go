@@ -15,6 +15,9 @@ type Hub struct {
 	register   chan *Client
 	unregister chan *Client
 	mu         sync.RWMutex
+
+	// Called for every command_executed event, see AddCommandListener
+	commandListeners []func(CommandExecutedData)
 }
 
 type Client struct {
@@ -28,6 +31,7 @@ type Client struct {
 
 type BroadcastMessage struct {
 	Type      string      `json:"type"`
+	Priority  string      `json:"priority,omitempty"` // "high" for alerts clients must show at once
 	Timestamp string      `json:"timestamp"`
 	Data      interface{} `json:"data"`
 }
@@ -48,6 +52,23 @@ type UserConnectionData struct {
 	Username string `json:"username"`
 }
 
+type RecordingAlertData struct {
+	Filename         string `json:"filename"`
+	Reason           string `json:"reason"`
+	StopAt           string `json:"stop_at,omitempty"`
+	SecondsRemaining int    `json:"seconds_remaining,omitempty"`
+	Attempts         int    `json:"attempts,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+type DiskAlertData struct {
+	Path       string `json:"path"`
+	FreeBytes  uint64 `json:"free_bytes"`
+	TotalBytes uint64 `json:"total_bytes"`
+	Threshold  uint64 `json:"threshold_bytes"`
+	Critical   bool   `json:"critical"`
+}
+
 const (
 	writeWait      = 10 * time.Second
 	pongWait       = 60 * time.Second
@@ -112,16 +133,28 @@ func (h *Hub) Run() {
 	}
 }
 
+// AddCommandListener registers a server-side observer of executed
+// commands. Listeners run on the request goroutine and must not block.
+// Must be called before the server starts handling requests.
+func (h *Hub) AddCommandListener(fn func(CommandExecutedData)) {
+	h.commandListeners = append(h.commandListeners, fn)
+}
+
 func (h *Hub) BroadcastCommandExecuted(userID, username, command string, payload interface{}) {
+	data := CommandExecutedData{
+		UserID:   userID,
+		Username: username,
+		Command:  command,
+		Payload:  payload,
+	}
+	for _, fn := range h.commandListeners {
+		fn(data)
+	}
+
 	msg := BroadcastMessage{
 		Type:      "command_executed",
 		Timestamp: time.Now().Format(time.RFC3339),
-		Data: CommandExecutedData{
-			UserID:   userID,
-			Username: username,
-			Command:  command,
-			Payload:  payload,
-		},
+		Data:      data,
 	}
 	h.broadcastMessage(msg)
 }
@@ -161,6 +194,72 @@ func (h *Hub) BroadcastUserDisconnected(userID, username string) {
 	h.broadcastMessage(msg)
 }
 
+func (h *Hub) BroadcastRecordingWarning(data RecordingAlertData) {
+	msg := BroadcastMessage{
+		Type:      "recording_warning",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data:      data,
+	}
+	h.broadcastMessage(msg)
+}
+
+func (h *Hub) BroadcastRecordingAutoStopped(data RecordingAlertData) {
+	msg := BroadcastMessage{
+		Type:      "recording_auto_stopped",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data:      data,
+	}
+	h.broadcastMessage(msg)
+}
+
+// BroadcastRecordingStopFailed tells operators that the server gave up
+// stopping a recording and it must be stopped by hand.
+func (h *Hub) BroadcastRecordingStopFailed(data RecordingAlertData) {
+	msg := BroadcastMessage{
+		Type:      "recording_stop_failed",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data:      data,
+	}
+	h.broadcastMessage(msg)
+}
+
+func (h *Hub) BroadcastDiskLow(data DiskAlertData) {
+	msg := BroadcastMessage{
+		Type:      "disk_low",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data:      data,
+	}
+	h.broadcastMessage(msg)
+}
+
+func (h *Hub) BroadcastBackgroundMusic(state BackgroundMusicState) {
+	msg := BroadcastMessage{
+		Type:      "background_music",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data:      state,
+	}
+	h.broadcastMessage(msg)
+}
+
+func (h *Hub) BroadcastRuleFired(firing RuleFiring) {
+	msg := BroadcastMessage{
+		Type:      "rule_fired",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data:      firing,
+	}
+	h.broadcastMessage(msg)
+}
+
+func (h *Hub) BroadcastEmergency(state EmergencyState) {
+	msg := BroadcastMessage{
+		Type:      "emergency",
+		Priority:  "high",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data:      state,
+	}
+	h.broadcastMessage(msg)
+}
+
 func (h *Hub) broadcastMessage(msg BroadcastMessage) {
 	jsonData, err := json.Marshal(msg)
 	if err != nil {